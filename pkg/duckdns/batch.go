@@ -0,0 +1,157 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+)
+
+// maxRequestWorkers bounds the number of concurrent duckdns requests of a
+// batch operation
+const maxRequestWorkers = 4
+
+// domainFromFQDN returns the duckdns domain label of a hostname, which is of
+// the form '<domain>.duckdns.org', '<suffix>.<domain>.duckdns.org' or
+// '*.<domain>.duckdns.org'
+func domainFromFQDN(fqdn string) string {
+	zone := strings.TrimSuffix(strings.TrimSuffix(fqdn, "."), "duckdns.org") //<suffix>.<domain>. or <domain>.
+	zone = strings.TrimSuffix(zone, ".")                                     //<suffix>.<domain> or <domain>
+	split := strings.Split(zone, ".")
+	return split[len(split)-1]
+}
+
+// UniqueDuckDNSDomains function to map hostnames, including wildcard and
+// nested names, to the deduplicated duckdns domain labels they belong to, in
+// order of first appearance
+func UniqueDuckDNSDomains(fqdns []string) ([]string, error) {
+	seen := make(map[string]bool)
+	var out []string
+	var errs []error
+
+	for _, fqdn := range fqdns {
+		name := strings.ToLower(strings.TrimSuffix(fqdn, "."))
+		if !strings.HasSuffix(name, ".duckdns.org") {
+			errs = append(errs, fmt.Errorf("%q is not a duckdns.org hostname", fqdn))
+			continue
+		}
+
+		domain := domainFromFQDN(name)
+		if domain == "" || domain == "*" {
+			errs = append(errs, fmt.Errorf("%q has no duckdns domain", fqdn))
+			continue
+		}
+
+		if !seen[domain] {
+			seen[domain] = true
+			out = append(out, domain)
+		}
+	}
+
+	return out, errors.Join(errs...)
+}
+
+// forEachDomain runs fn for every domain with at most maxRequestWorkers in
+// flight, stopping to start new calls once ctx is done, and collects the
+// errors in a MultiError
+func forEachDomain(ctx context.Context, domains []string, fn func(ctx context.Context, domain string) error) error {
+	var errs MultiError
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+	sem := make(chan struct{}, maxRequestWorkers)
+
+	for _, domain := range domains {
+		wg.Add(1)
+		go func(domain string) {
+			defer wg.Done()
+
+			var err error
+			select {
+			case sem <- struct{}{}:
+				err = fn(ctx, domain)
+				<-sem
+			case <-ctx.Done():
+				err = ctx.Err()
+			}
+
+			if err != nil {
+				mu.Lock()
+				errs.add(domain, err)
+				mu.Unlock()
+			}
+		}(domain)
+	}
+	wg.Wait()
+
+	return errs.errOrNil()
+}
+
+// ClearRecords function to clear the TXT record of every duckdns domain the
+// given hostnames belong to, each domain is cleared once
+func (c *ClientC) ClearRecords(ctx context.Context, domains []string) error {
+	unique, err := UniqueDuckDNSDomains(domains)
+	if err != nil {
+		return err
+	}
+
+	return forEachDomain(ctx, unique, func(ctx context.Context, domain string) error {
+		_, err := c.clearRecord(ctx, []string{domain}, "")
+		return err
+	})
+}
+
+// GetRecordsAll function to get the TXT records of every configured domain
+// concurrently, a failed lookup is reported in the joined error and leaves
+// its domain out of the result
+func (c *ClientC) GetRecordsAll(ctx context.Context) (map[string][]string, error) {
+	out := make(map[string][]string, len(c.Config.DomainNames))
+	var mu sync.Mutex
+
+	err := forEachDomain(ctx, c.Config.DomainNames, func(ctx context.Context, domain string) error {
+		txt, err := c.domainTXT(ctx, domain)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		out[domain] = txt
+		mu.Unlock()
+		return nil
+	})
+
+	return out, err
+}
+
+// UpdateRecordsPerDomain function to set a distinct TXT value per domain,
+// which a single multi-domain update can't do, with one request per domain
+func (c *ClientC) UpdateRecordsPerDomain(ctx context.Context, records map[string]string) error {
+	domains := make([]string, 0, len(records))
+	for domain := range records {
+		domains = append(domains, domain)
+	}
+	if err := validateDomains(domains, c.domainSeparator); err != nil {
+		return err
+	}
+
+	return forEachDomain(ctx, domains, func(ctx context.Context, domain string) error {
+		_, err := c.updateRecord(ctx, []string{domain}, records[domain])
+		return err
+	})
+}
+
+// AffectedDomains function to return the duckdns domains a combined IP
+// update or clear touches, lowercased and deduplicated in configured order,
+// to preview a destructive operation
+func (c *ClientC) AffectedDomains() []string {
+	seen := make(map[string]bool, len(c.Config.DomainNames))
+	var out []string
+	for _, name := range c.Config.DomainNames {
+		domain := domainFromFQDN(strings.ToLower(strings.TrimSpace(name)))
+		if domain != "" && !seen[domain] {
+			seen[domain] = true
+			out = append(out, domain)
+		}
+	}
+	return out
+}
@@ -0,0 +1,285 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// failDomains returns a handler answering KO to the requests of the given
+// domains and OK to the others
+func failDomains(domains ...string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		if slices.Contains(domains, r.URL.Query().Get("domains")) {
+			w.Write([]byte("KO"))
+			return
+		}
+		w.Write([]byte("OK"))
+	}
+}
+
+// domains returns the sorted domains parameter of every request received
+func (l *requestLog) domains() []string {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	out := make([]string, 0, len(l.requests))
+	for _, r := range l.requests {
+		out = append(out, r.URL.Query().Get("domains"))
+	}
+	slices.Sort(out)
+	return out
+}
+
+func TestClearRecordsPartialFailure(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(failDomains("bad")))
+
+	err := c.ClearRecords(context.Background(), []string{
+		"_acme-challenge.good.duckdns.org",
+		"*.good.duckdns.org",
+		"bad.duckdns.org",
+	})
+
+	var multi *MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 1 || multi.Errors[0].Domain != "bad" {
+		t.Fatalf("ClearRecords() error = %v, want a failure of bad only", err)
+	}
+	if !errors.Is(err, ErrRequestRejected) {
+		t.Errorf("ClearRecords() error = %v, want it to wrap %v", err, ErrRequestRejected)
+	}
+	if got, want := log.domains(), []string{"bad", "good"}; !slices.Equal(got, want) {
+		t.Errorf("cleared domains = %v, want %v", got, want)
+	}
+}
+
+func TestClearRecordsInvalidHostname(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")))
+
+	if err := c.ClearRecords(context.Background(), []string{"good.duckdns.org", "example.com"}); err == nil {
+		t.Fatal("ClearRecords() error = nil, want an invalid hostname error")
+	}
+	if got := log.count(); got != 0 {
+		t.Errorf("requests = %d, want 0", got)
+	}
+}
+
+func TestClearRecordsCanceled(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if err := c.ClearRecords(ctx, []string{"a.duckdns.org", "b.duckdns.org"}); !errors.Is(err, context.Canceled) {
+		t.Fatalf("ClearRecords() error = %v, want %v", err, context.Canceled)
+	}
+}
+
+func TestUniqueDuckDNSDomains(t *testing.T) {
+	got, err := UniqueDuckDNSDomains([]string{
+		"example.duckdns.org",
+		"*.example.duckdns.org",
+		"_acme-challenge.example.duckdns.org.",
+		"deep.nested.Other.duckdns.org",
+		"other.duckdns.org",
+		"third.duckdns.org",
+	})
+	if err != nil {
+		t.Fatalf("UniqueDuckDNSDomains() error = %v", err)
+	}
+	if want := []string{"example", "other", "third"}; !slices.Equal(got, want) {
+		t.Errorf("UniqueDuckDNSDomains() = %v, want %v", got, want)
+	}
+}
+
+func TestUniqueDuckDNSDomainsInvalid(t *testing.T) {
+	got, err := UniqueDuckDNSDomains([]string{
+		"example.duckdns.org",
+		"example.com",
+		"duckdns.org",
+		"*.duckdns.org",
+		"notduckdns.org",
+	})
+	if err == nil {
+		t.Fatal("UniqueDuckDNSDomains() error = nil, want an error")
+	}
+	for _, fqdn := range []string{"example.com", `"duckdns.org"`, `"*.duckdns.org"`, "notduckdns.org"} {
+		if !strings.Contains(err.Error(), fqdn) {
+			t.Errorf("UniqueDuckDNSDomains() error = %q, want it to name %s", err, fqdn)
+		}
+	}
+	if want := []string{"example"}; !slices.Equal(got, want) {
+		t.Errorf("UniqueDuckDNSDomains() = %v, want %v", got, want)
+	}
+}
+
+func TestGetRecordsAll(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("first.duckdns.org", "one")
+	zone.setTXT("second.duckdns.org", "two", "three")
+	zone.setRcode("broken.duckdns.org", dns.RcodeRefused)
+
+	c := newTestClient(t, newTestConfig("first", "second", "missing", "broken"), respond(http.StatusOK, "OK"), WithResolver(zone.resolver()))
+
+	got, err := c.GetRecordsAll(context.Background())
+	var multi *MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 1 || multi.Errors[0].Domain != "broken" {
+		t.Fatalf("GetRecordsAll() error = %v, want a failure of broken only", err)
+	}
+
+	want := map[string][]string{"first": {"one"}, "second": {"two", "three"}, "missing": nil}
+	if len(got) != len(want) {
+		t.Errorf("GetRecordsAll() = %v, want %v", got, want)
+	}
+	for domain, records := range want {
+		sorted := slices.Clone(got[domain])
+		slices.Sort(sorted)
+		slices.Sort(records)
+		if !slices.Equal(sorted, records) {
+			t.Errorf("GetRecordsAll()[%q] = %q, want %q", domain, got[domain], records)
+		}
+	}
+}
+
+func TestUpdateRecordsPerDomain(t *testing.T) {
+	records := map[string]string{"first": "one", "second": "two", "third": "three"}
+
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")))
+
+	if err := c.UpdateRecordsPerDomain(context.Background(), records); err != nil {
+		t.Fatalf("UpdateRecordsPerDomain() error = %v", err)
+	}
+	got := make(map[string]string)
+	for _, req := range log.requests {
+		q := req.URL.Query()
+		if _, dup := got[q.Get("domains")]; dup {
+			t.Errorf("domain %v requested twice", q.Get("domains"))
+		}
+		got[q.Get("domains")] = q.Get("txt")
+	}
+	if !maps.Equal(got, records) {
+		t.Errorf("requested records = %v, want %v", got, records)
+	}
+}
+
+func TestUpdateRecordsPerDomainBounded(t *testing.T) {
+	records := make(map[string]string)
+	for i := 0; i < 3*maxRequestWorkers; i++ {
+		records["domain"+strconv.Itoa(i)] = "value"
+	}
+	tracker := newOverlapTracker()
+	c := newTestClient(t, newTestConfig(), tracker.ServeHTTP)
+
+	if err := c.UpdateRecordsPerDomain(context.Background(), records); err != nil {
+		t.Fatalf("UpdateRecordsPerDomain() error = %v", err)
+	}
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	if tracker.maxTotal > maxRequestWorkers || tracker.maxTotal < 2 {
+		t.Errorf("peak concurrent requests = %d, want concurrent requests bounded by %d", tracker.maxTotal, maxRequestWorkers)
+	}
+}
+
+func TestUpdateRecordsPerDomainPartialFailure(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), failDomains("bad"))
+
+	err := c.UpdateRecordsPerDomain(context.Background(), map[string]string{"good": "one", "bad": "two"})
+	var multi *MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 1 || multi.Errors[0].Domain != "bad" {
+		t.Fatalf("UpdateRecordsPerDomain() error = %v, want a failure of bad only", err)
+	}
+}
+
+// numberedDomains returns n domain labels
+func numberedDomains(n int) []string {
+	domains := make([]string, n)
+	for i := range domains {
+		domains[i] = "domain" + strconv.Itoa(i)
+	}
+	return domains
+}
+
+func TestWithMaxDomainsPerRequest(t *testing.T) {
+	tests := []struct {
+		name       string
+		domains    int
+		opts       []Option
+		wantChunks int
+	}{
+		{name: "capped", domains: 7, opts: []Option{WithMaxDomainsPerRequest(3)}, wantChunks: 3},
+		{name: "exact", domains: 6, opts: []Option{WithMaxDomainsPerRequest(3)}, wantChunks: 2},
+		{name: "default", domains: defaultMaxDomainsPerRequest + 5, wantChunks: 2},
+		{name: "single", domains: defaultMaxDomainsPerRequest, wantChunks: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			domains := numberedDomains(tt.domains)
+			var log requestLog
+			c := newTestClient(t, newTestConfig(domains...), log.wrap(respond(http.StatusOK, "OK")), tt.opts...)
+
+			if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+				t.Fatalf("UpdateRecord() error = %v", err)
+			}
+			if got := log.count(); got != tt.wantChunks {
+				t.Errorf("requests = %d, want %d", got, tt.wantChunks)
+			}
+			var sent []string
+			for _, chunk := range log.domains() {
+				sent = append(sent, strings.Split(chunk, ",")...)
+			}
+			slices.Sort(sent)
+			slices.Sort(domains)
+			if !slices.Equal(sent, domains) {
+				t.Errorf("updated domains = %q, want every domain once", sent)
+			}
+		})
+	}
+}
+
+func TestWithMaxDomainsPerRequestChunkFailure(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(numberedDomains(4)...), log.wrap(failDomains("domain2,domain3")),
+		WithMaxDomainsPerRequest(2))
+
+	_, err := c.UpdateRecord(context.Background(), "value")
+	var multi *MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 1 || multi.Errors[0].Domain != "domain2,domain3" {
+		t.Fatalf("UpdateRecord() error = %v, want a failure of the second chunk only", err)
+	}
+	if got := log.count(); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+}
+
+func TestWithMaxDomainsPerRequestInvalid(t *testing.T) {
+	if err := WithMaxDomainsPerRequest(0)(&ClientC{}); err == nil {
+		t.Error("WithMaxDomainsPerRequest(0) error = nil, want an error")
+	}
+}
+
+func TestAffectedDomains(t *testing.T) {
+	c := newTestClient(t, newTestConfig(
+		"Home", " home ", "home.duckdns.org", "_acme-challenge.lab.duckdns.org.", "lab", "other",
+	), respond(http.StatusOK, "OK"))
+
+	if got, want := c.AffectedDomains(), []string{"home", "lab", "other"}; !slices.Equal(got, want) {
+		t.Errorf("AffectedDomains() = %q, want %q", got, want)
+	}
+}
+
+func TestAffectedDomainsEmpty(t *testing.T) {
+	c := &ClientC{Config: &ConfigC{Token: testToken}}
+	if got := c.AffectedDomains(); len(got) != 0 {
+		t.Errorf("AffectedDomains() = %q, want none", got)
+	}
+}
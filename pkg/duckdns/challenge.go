@@ -0,0 +1,73 @@
+package duckdns
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"strings"
+)
+
+// challengeTokenLength is the length of an unpadded base64url SHA-256 digest,
+// which is what ACME DNS-01 publishes in the TXT record
+const challengeTokenLength = 43
+
+// ValidateChallengeToken function to check that value is a well-formed ACME
+// DNS-01 TXT value
+func ValidateChallengeToken(value string) error {
+	if len(value) != challengeTokenLength {
+		return fmt.Errorf("challenge token must be %d characters, got %d", challengeTokenLength, len(value))
+	}
+
+	for i, r := range value {
+		if !(r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9' || r == '-' || r == '_') {
+			return fmt.Errorf("challenge token has invalid character %q at position %d", r, i)
+		}
+	}
+
+	if _, err := base64.RawURLEncoding.Strict().DecodeString(value); err != nil {
+		return fmt.Errorf("challenge token is not valid base64url: %v", err)
+	}
+
+	return nil
+}
+
+// WithChallengeTokenValidation option to make UpdateRecord reject values that
+// are not well-formed ACME challenge tokens before sending them
+func WithChallengeTokenValidation(enabled bool) Option {
+	return func(c *ClientC) error {
+		c.validateChallengeToken = enabled
+		return nil
+	}
+}
+
+// ChallengeKey function to return a canonical key for a domain and TXT value,
+// used by every cache and coalescing feature so they agree. The domain is
+// normalized, so "Foo.duckdns.org." and "foo" give the same key.
+func ChallengeKey(domain, value string) string {
+	domain = strings.ToLower(strings.TrimSuffix(domain, "."))
+	if strings.HasSuffix(domain, ".duckdns.org") {
+		domain = domainFromFQDN(domain)
+	}
+
+	sum := sha256.Sum256([]byte(domain + "\x00" + value))
+	return hex.EncodeToString(sum[:])
+}
+
+// challengePrefix is the label ACME prepends to the name of a DNS-01 record
+const challengePrefix = "_acme-challenge."
+
+// ResolveChallenge function to return the duckdns domain label a challenge
+// hostname belongs to, and the TXT record name ACME looks up for it, for
+// plain, nested and wildcard hostnames, with or without the challenge label
+func ResolveChallenge(fqdn string) (domain, recordName string, err error) {
+	name := strings.TrimPrefix(strings.ToLower(strings.TrimSuffix(fqdn, ".")), challengePrefix)
+	name = strings.TrimPrefix(name, "*.")
+
+	domains, err := UniqueDuckDNSDomains([]string{name})
+	if err != nil {
+		return "", "", err
+	}
+
+	return domains[0], challengePrefix + name, nil
+}
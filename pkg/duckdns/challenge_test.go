@@ -0,0 +1,121 @@
+package duckdns
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// validChallengeToken returns a well-formed ACME DNS-01 TXT value
+func validChallengeToken(seed string) string {
+	sum := sha256.Sum256([]byte(seed))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
+
+func TestValidateChallengeToken(t *testing.T) {
+	tests := []struct {
+		name    string
+		value   string
+		wantErr bool
+	}{
+		{name: "valid", value: validChallengeToken("a")},
+		{name: "valid with url alphabet", value: "-_" + validChallengeToken("b")[2:]},
+		{name: "empty", value: "", wantErr: true},
+		{name: "too short", value: validChallengeToken("a")[:42], wantErr: true},
+		{name: "too long", value: validChallengeToken("a") + "A", wantErr: true},
+		{name: "standard alphabet", value: "+/" + validChallengeToken("a")[2:], wantErr: true},
+		{name: "padded", value: validChallengeToken("a")[:42] + "=", wantErr: true},
+		{name: "non-canonical trailing bits", value: strings.Repeat("A", 42) + "B", wantErr: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			err := ValidateChallengeToken(tt.value)
+			if (err != nil) != tt.wantErr {
+				t.Errorf("ValidateChallengeToken(%q) error = %v, wantErr %v", tt.value, err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestUpdateRecordChallengeTokenValidation(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")), WithChallengeTokenValidation(true))
+
+	if _, err := c.UpdateRecord(context.Background(), "not-a-token"); err == nil {
+		t.Error("UpdateRecord() error = nil, want a malformed token error")
+	}
+	if got := log.count(); got != 0 {
+		t.Errorf("requests = %d, want 0 for a malformed token", got)
+	}
+
+	if _, err := c.UpdateRecord(context.Background(), validChallengeToken("a")); err != nil {
+		t.Errorf("UpdateRecord() error = %v", err)
+	}
+}
+
+func TestChallengeKey(t *testing.T) {
+	want := ChallengeKey("foo", "value")
+	for _, domain := range []string{"FOO", "foo.duckdns.org", "Foo.DuckDNS.org.", "_acme-challenge.foo.duckdns.org"} {
+		if got := ChallengeKey(domain, "value"); got != want {
+			t.Errorf("ChallengeKey(%q, value) = %v, want the key of foo", domain, got)
+		}
+	}
+
+	different := [][2]string{
+		{"bar", "value"},
+		{"foo", "Value"},
+		{"foo", "value2"},
+		{"foo", ""},
+		{"foov", "alue"},
+	}
+	for _, in := range different {
+		if got := ChallengeKey(in[0], in[1]); got == want {
+			t.Errorf("ChallengeKey(%q, %q) = the key of foo and value, want a different key", in[0], in[1])
+		}
+	}
+}
+
+func TestResolveChallenge(t *testing.T) {
+	tests := []struct {
+		fqdn       string
+		domain     string
+		recordName string
+	}{
+		{fqdn: "example.duckdns.org", domain: "example", recordName: "_acme-challenge.example.duckdns.org"},
+		{fqdn: "Example.DuckDNS.org.", domain: "example", recordName: "_acme-challenge.example.duckdns.org"},
+		{fqdn: "_acme-challenge.example.duckdns.org.", domain: "example", recordName: "_acme-challenge.example.duckdns.org"},
+		{fqdn: "*.example.duckdns.org", domain: "example", recordName: "_acme-challenge.example.duckdns.org"},
+		{fqdn: "www.example.duckdns.org", domain: "example", recordName: "_acme-challenge.www.example.duckdns.org"},
+		{fqdn: "_acme-challenge.a.b.example.duckdns.org", domain: "example", recordName: "_acme-challenge.a.b.example.duckdns.org"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.fqdn, func(t *testing.T) {
+			domain, recordName, err := ResolveChallenge(tt.fqdn)
+			if err != nil {
+				t.Fatalf("ResolveChallenge() error = %v", err)
+			}
+			if domain != tt.domain || recordName != tt.recordName {
+				t.Errorf("ResolveChallenge() = %q, %q, want %q, %q", domain, recordName, tt.domain, tt.recordName)
+			}
+
+			// the record name resolves back to itself
+			domain2, recordName2, err := ResolveChallenge(recordName)
+			if err != nil || domain2 != domain || recordName2 != recordName {
+				t.Errorf("ResolveChallenge(%q) = %q, %q, %v, want a round trip", recordName, domain2, recordName2, err)
+			}
+		})
+	}
+}
+
+func TestResolveChallengeInvalid(t *testing.T) {
+	for _, fqdn := range []string{"", "example.com", "_acme-challenge.duckdns.org", "*.duckdns.org"} {
+		if domain, _, err := ResolveChallenge(fqdn); err == nil {
+			t.Errorf("ResolveChallenge(%q) = %q, want an error", fqdn, domain)
+		}
+	}
+}
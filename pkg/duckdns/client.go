@@ -1,35 +1,151 @@
 package duckdns
 
 import (
+	"compress/gzip"
 	"context"
 	"errors"
+	"expvar"
 	"fmt"
+	"io"
 	"io/ioutil"
 	"net"
 	"net/http"
+	"net/netip"
+	neturl "net/url"
+	"slices"
+	"sort"
 	"strconv"
 	"strings"
+	"sync"
+	"time"
 
 	"k8s.io/klog/v2"
 )
 
 const (
 	defaultBaseURL = "https://www.duckdns.org"
-	domainStub     = "/update?domains="
-	tokenStub      = "&token="
-	ip4Stub        = "&ip="
-	ip6Stub        = "&ipv6="
-	txtStub        = "&txt="
-	verboseStub    = "&verbose="
-	clearStub      = "&clear="
+	updatePath     = "/update"
+
+	// defaultDomainSeparator separates the domains of a multi-domain request
+	defaultDomainSeparator = ","
+
+	domainsParam = "domains"
+	tokenParam   = "token"
+	ip4Param     = "ip"
+	ip6Param     = "ipv6"
+	txtParam     = "txt"
+	clearParam   = "clear"
+	verboseParam = "verbose"
 
 	defaultUserAgent = "duckdns-go/1.0.3"
+
+	obfuscatedToken = "*********"
+	// tokenSentinel stands for the token while the masked query is encoded,
+	// it is left unescaped and then swapped for the literal obfuscatedToken
+	tokenSentinel = "DUCKDNSTOKEN"
+)
+
+// ErrRequestRejected is returned when duckdns answers a request with a 2xx
+// status and a body not reporting success, typically KO. Any other status is
+// reported as a StatusError.
+var ErrRequestRejected = errors.New("request rejected by duckdns")
+
+// StatusError is returned when duckdns or a proxy answers a request with a
+// non-2xx status, whose body is never taken for a success
+type StatusError struct {
+	StatusCode int
+	Status     string
+
+	// Body is the redacted body snippet, set when enabled with WithErrorBody
+	Body string
+}
+
+func (e *StatusError) Error() string {
+	if e.Body != "" {
+		return fmt.Sprintf("unexpected status %v: %q", e.Status, e.Body)
+	}
+	return fmt.Sprintf("unexpected status %v", e.Status)
+}
+
+// ErrEmptyToken is returned when a request is attempted with an empty token
+var ErrEmptyToken = errors.New("duckdns token is empty")
+
+// Status type of the status line duckdns answers requests with
+type Status string
+
+const (
+	// StatusOK reports a successful request
+	StatusOK Status = "OK"
+	// StatusKO reports a rejected request, e.g. a bad token or domain
+	StatusKO Status = "KO"
+	// StatusUnknown is returned for a body with no recognizable status
+	StatusUnknown Status = ""
 )
 
+// ParseStatus function to return the status of a response body from its
+// first token, in any case
+func ParseStatus(body string) Status {
+	fields := strings.Fields(body)
+	if len(fields) == 0 {
+		return StatusUnknown
+	}
+
+	switch status := Status(strings.ToUpper(fields[0])); status {
+	case StatusOK, StatusKO:
+		return status
+	}
+	return StatusUnknown
+}
+
+// DefaultSuccessMatcher function to report success when the first token of
+// the body is OK, in any case, so that verbose and trailing data are accepted
+func DefaultSuccessMatcher(body string) bool {
+	return ParseStatus(body) == StatusOK
+}
+
+func (c *ClientC) succeeded(body string) bool {
+	if c.SuccessMatcher != nil {
+		return c.SuccessMatcher(body)
+	}
+	return DefaultSuccessMatcher(body)
+}
+
 // Response structure containing the http response and the data from the body
 type Response struct {
 	HTTPResponse *http.Response
 	Data         string
+
+	// RateLimitRemaining and RateLimitReset are parsed from the
+	// X-RateLimit-Remaining and X-RateLimit-Reset headers when present
+	RateLimitRemaining int
+	RateLimitReset     time.Time
+
+	// Attempts is the number of http attempts the call took, retries and
+	// the fallback base url included
+	Attempts int
+
+	// PreviousTXT holds the TXT value each domain resolved right before a TXT
+	// update, empty for a domain without one, when enabled with
+	// WithPreviousTXTCapture
+	PreviousTXT map[string]string
+}
+
+// parseRateLimit fills the rate limit fields of response from the headers,
+// a reset value is either a unix timestamp or a number of seconds from now
+func parseRateLimit(header http.Header, response *Response) {
+	if remaining, err := strconv.Atoi(header.Get("X-RateLimit-Remaining")); err == nil {
+		response.RateLimitRemaining = remaining
+	}
+
+	reset, err := strconv.ParseInt(header.Get("X-RateLimit-Reset"), 10, 64)
+	if err != nil || reset <= 0 {
+		return
+	}
+	if reset > 1e9 {
+		response.RateLimitReset = time.Unix(reset, 0)
+	} else {
+		response.RateLimitReset = time.Now().Add(time.Duration(reset) * time.Second)
+	}
 }
 
 // Config structure containing the client configuration
@@ -39,14 +155,119 @@ type ConfigC struct {
 	IPv4        string
 	IPv6        string
 	Verbose     bool
+
+	// TokenFile is read for the token when Token is empty, and again by
+	// ClientC.ReloadToken
+	TokenFile string
+
+	// Methods sets the http method of each operation, GET or POST, an
+	// operation missing from it uses GET
+	Methods map[Operation]string
+
+	// TokenIssuedAt and TokenMaxAge describe the rotation policy of the token,
+	// a warning is logged once it is within TokenExpiryWarning of its max age
+	TokenIssuedAt      time.Time
+	TokenMaxAge        time.Duration
+	TokenExpiryWarning time.Duration
+}
+
+// Operation type of the kind of an update request
+type Operation string
+
+const (
+	// OperationIP updates or auto-detects the ip addresses
+	OperationIP Operation = "ip"
+	// OperationTXT updates the TXT record
+	OperationTXT Operation = "txt"
+	// OperationClear clears the TXT record or the ip addresses
+	OperationClear Operation = "clear"
+)
+
+// operationOf returns the operation of the parameters of an update request
+func operationOf(params neturl.Values) Operation {
+	switch {
+	case params.Has(clearParam):
+		return OperationClear
+	case params.Has(txtParam):
+		return OperationTXT
+	}
+	return OperationIP
+}
+
+// method returns the http method of the update request with params
+func (c *ConfigC) method(params neturl.Values) string {
+	if method := c.Methods[operationOf(params)]; method != "" {
+		return method
+	}
+	return http.MethodGet
 }
 
 // Valid function to check if the client configuration is valid
 func (c *ConfigC) Valid() bool {
-	if c.Token != "" && len(c.DomainNames) > 0 {
-		return true
+	return c.Validate() == nil
+}
+
+// Validate function to check the client configuration and describe what is
+// wrong with it
+func (c *ConfigC) Validate() error {
+	if c.Token == "" {
+		return errors.New("token must be non-empty")
+	}
+	if len(c.DomainNames) == 0 {
+		return errors.New("at least one domain is required")
+	}
+
+	for operation, method := range c.Methods {
+		if method != http.MethodGet && method != http.MethodPost {
+			return fmt.Errorf("unsupported method %q for %v operation, expected GET or POST", method, operation)
+		}
 	}
-	return false
+
+	return nil
+}
+
+// Warnings function to describe what looks wrong with a valid client
+// configuration, a token and domain that look swapped. The checks are
+// heuristics and may be false positives, NewClient only logs them.
+func (c *ConfigC) Warnings() []string {
+	var warnings []string
+	if looksLikeDomain(c.Token) {
+		warnings = append(warnings, "token looks like a domain, token and domain names may be swapped")
+	}
+	for _, domain := range c.DomainNames {
+		if looksLikeToken(domain) {
+			warnings = append(warnings, "a domain name looks like a token, token and domain names may be swapped")
+			break
+		}
+	}
+	return warnings
+}
+
+// looksLikeDomain reports whether a token value is a hostname, duckdns tokens
+// never contain dots
+func looksLikeDomain(token string) bool {
+	return strings.Contains(token, ".")
+}
+
+// looksLikeToken reports whether a domain value has the uuid shape of a
+// duckdns token
+func looksLikeToken(domain string) bool {
+	if len(domain) != 36 {
+		return false
+	}
+	for i, r := range domain {
+		switch i {
+		case 8, 13, 18, 23:
+			if r != '-' {
+				return false
+			}
+		default:
+			if !strings.ContainsRune("0123456789abcdefABCDEF", r) {
+				return false
+			}
+		}
+	}
+	return true
 }
 
 // Client structure
@@ -54,20 +275,128 @@ type ClientC struct {
 	httpClient *http.Client
 	BaseURL    string
 	UserAgent  string
+	Retry      RetryConfig
+
+	// SuccessMatcher decides whether the body of a 2xx response reports
+	// success, defaults to DefaultSuccessMatcher when nil
+	SuccessMatcher func(body string) bool
+
+	// RequestSigner is called with every request once its headers are set,
+	// e.g. to add an HMAC or bearer token for an authenticating proxy. An
+	// error aborts the request.
+	RequestSigner func(req *http.Request) error
+
+	// RequestInterceptor is called with every request right before it is
+	// sent, e.g. to audit or enforce a policy on it. The url carries the
+	// token, which must be masked before it is logged. An error aborts
+	// the request.
+	RequestInterceptor func(req *http.Request) error
+
+	// PostUpdateVerifier is called for every domain once a TXT update
+	// succeeded, e.g. to check the value against external monitoring. Its
+	// error is returned by the update method.
+	PostUpdateVerifier func(ctx context.Context, domain, value string) error
+
+	// ResponseValidator is called with every response reported successful,
+	// e.g. to require a verbose UPDATED result. Its error fails the request,
+	// without retry.
+	ResponseValidator func(response *Response) error
+
+	// AlwaysVerbose sends verbose=true with every request and logs the parsed
+	// result, without changing what the methods return
+	AlwaysVerbose bool
 
 	Config *ConfigC
+
+	// transport is only set when the http client is managed by the package
+	transport  *http.Transport
+	resolver   *net.Resolver
+	headers    http.Header
+	hedging    *hedging
+	recorder   *requestRecorder
+	vars       *expvar.Map
+	superseder *superseder
+	limiter    *rateLimiter
+	dnsSem     chan struct{}
+
+	fallbackBaseURL string
+	domainSeparator string
+
+	maxDomainsPerRequest    int
+	initialPropagationDelay time.Duration
+	errorBodySize           int
+	logPrefix               string
+
+	ipEchoEndpoints        []string
+	changedTokens          []string
+	validateChallengeToken bool
+	resolverUncached       bool
+	sortDomains            bool
+	reconcileClear         bool
+	warnOverwrite          bool
+	capturePrevious        bool
+	selfHealing            bool
+	authFallbackOnce       sync.Once
+	tokenMu                sync.RWMutex
+	headersMu              sync.RWMutex
+	domainLocks            domainLocks
+	outcomes               outcomes
+
+	serverResolversMu sync.Mutex
+	serverResolvers   map[string]*net.Resolver
+	resolverDial      dialFunc
+	authServers       []string
+
+	shutdownCtx context.Context
+	shutdown    context.CancelCauseFunc
+
+	// after waits for the initial propagation delay, time.After when nil
+	after func(d time.Duration) <-chan time.Time
 }
 
-// NewClient function to return a valid duckdns client
-func NewClient(httpClient *http.Client, config *ConfigC) *ClientC {
-	if !config.Valid() {
-		klog.Fatal("Configuration is not valid")
+// NewClient function to return a valid duckdns client, a nil httpClient makes
+// the client manage its own transport
+func NewClient(httpClient *http.Client, config *ConfigC, opts ...Option) *ClientC {
+	if config.Token == "" && config.TokenFile != "" {
+		if err := config.LoadTokenFile(); err != nil {
+			klog.Fatalf("Configuration is not valid: %v", err)
+		}
+	}
+
+	if err := config.Validate(); err != nil {
+		klog.Fatalf("Configuration is not valid: %v", err)
+	}
+
+	var transport *http.Transport
+	if httpClient == nil {
+		transport = newManagedTransport()
+		httpClient = &http.Client{Transport: transport}
 	}
 
 	c := &ClientC{httpClient: httpClient,
-		BaseURL:   defaultBaseURL,
-		UserAgent: defaultUserAgent,
-		Config:    config}
+		transport:       transport,
+		BaseURL:         defaultBaseURL,
+		UserAgent:       defaultUserAgent,
+		Retry:           defaultRetryConfig(),
+		domainSeparator: defaultDomainSeparator,
+		resolver:        net.DefaultResolver,
+		Config:          config,
+
+		initialPropagationDelay: defaultInitialPropagationDelay}
+	if transport != nil {
+		httpClient.CheckRedirect = c.checkRedirect(RedirectRefuseCrossHost)
+	}
+	c.shutdownCtx, c.shutdown = context.WithCancelCause(context.Background())
+
+	for _, opt := range opts {
+		if err := opt(c); err != nil {
+			klog.Fatalf("Client option is not valid: %v", err)
+		}
+	}
+	for _, warning := range config.Warnings() {
+		klog.Warningf("%sConfiguration may be wrong: %s", c.logPrefix, warning)
+	}
+	c.CheckTokenAge(time.Now())
 	return c
 }
 
@@ -76,39 +405,180 @@ func (c *ClientC) SetUserAgent(ua string) {
 	c.UserAgent = ua
 }
 
-// SetVerbose function to set the response of the client request to verbose=true
+// SetVerbose function to set the response of the client request to verbose=true,
+// it races with in-flight requests so concurrent callers should rely on the
+// request-scoped UpdateRecordVerbose and ClearRecordVerbose instead
 func (c *ConfigC) SetVerbose(verbose bool) {
 	c.Verbose = verbose
 }
 
-func (c *ClientC) makeGetRequest(ctx context.Context, path, pathObf string, response *Response) (*http.Response, error) {
+// IsVerbose function to report whether the configuration requests verbose
+// responses, as set by SetVerbose
+func (c *ConfigC) IsVerbose() bool {
+	return c.Verbose
+}
+
+// obfuscate masks every occurrence of the configured token in s, so that
+// urls and errors can be logged safely
+func (c *ClientC) obfuscate(s string) string {
+	token := c.token()
+	if token == "" {
+		return s
+	}
+	return strings.ReplaceAll(s, token, obfuscatedToken)
+}
+
+func (c *ClientC) makeRequest(ctx context.Context, method, path, pathObf string, response *Response) (*http.Response, error) {
+	ctx, stop, err := c.shutdownContext(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer stop()
+
+	ctx, cancel := requestContext(ctx)
+	defer cancel()
+
+	resp, err := c.fallbackRequest(ctx, method, path, pathObf, response)
+	if err != nil && errors.Is(context.Cause(ctx), ErrShuttingDown) {
+		err = fmt.Errorf("%w: %w", ErrShuttingDown, err)
+	}
+	response.HTTPResponse = resp
+	if err == nil && c.ResponseValidator != nil {
+		if err = c.ResponseValidator(response); err != nil {
+			err = fmt.Errorf("response rejected by validator: %w", err)
+		}
+	}
+	c.outcomes.record(time.Now(), err)
+	c.recordVars(response, err)
+	return resp, err
+}
+
+func (c *ClientC) fallbackRequest(ctx context.Context, method, path, pathObf string, response *Response) (*http.Response, error) {
+	resp, err := c.retryRequest(ctx, method, c.BaseURL, path, pathObf, response)
+	if err == nil || c.fallbackBaseURL == "" || ctx.Err() != nil || retriesDisabled(ctx) || errors.Is(err, ErrRequestRejected) {
+		return resp, err
+	}
+
+	klog.Warningf("%sRequests to %v failed: %v, failing over to %v", c.logPrefix, c.BaseURL, err, c.fallbackBaseURL)
+	resp, err = c.makeAttempt(ctx, method, c.fallbackBaseURL, path, pathObf, response)
+	if err == nil {
+		c.logVerbose(ctx, response)
+	}
+	return resp, err
+}
+
+func (c *ClientC) retryRequest(ctx context.Context, method, baseURL, path, pathObf string, response *Response) (*http.Response, error) {
+	for attempt := 1; ; attempt++ {
+		resp, err := c.makeAttempt(ctx, method, baseURL, path, pathObf, response)
+		attempts := c.Retry.maxAttempts(resp, err)
+		if retriesDisabled(ctx) {
+			attempts = 1
+		}
+		if attempt >= attempts || ctx.Err() != nil || !c.Retry.shouldRetry(resp, err) {
+			if err == nil {
+				c.logVerbose(ctx, response)
+			}
+			return resp, err
+		}
+		if err == nil {
+			err = fmt.Errorf("unexpected status %v", resp.Status)
+		}
+
+		delay := c.Retry.backoff(ctx, attempt)
+		if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < delay+c.Retry.MinAttemptTime {
+			klog.Warningf("%sRequest attempt %d/%d to %v failed: %v, not retrying before deadline", c.logPrefix, attempt, attempts, baseURL+pathObf, err)
+			return resp, fmt.Errorf("%w after attempt %d: %w", ErrInsufficientRetryTime, attempt, err)
+		}
 
-	req, err := c.newRequest(http.MethodGet, path, pathObf)
+		klog.Warningf("%sRequest attempt %d/%d to %v failed: %v, retrying in %v", c.logPrefix, attempt, attempts, baseURL+pathObf, err, delay)
+
+		select {
+		case <-ctx.Done():
+			return resp, ctx.Err()
+		case <-time.After(delay):
+		}
+	}
+}
+
+func (c *ClientC) makeAttempt(ctx context.Context, method, baseURL, path, pathObf string, response *Response) (*http.Response, error) {
+
+	req, err := c.newRequest(ctx, method, baseURL, path, pathObf)
 	if err != nil {
 		return nil, err
 	}
 
+	if response != nil {
+		response.Attempts++
+	}
+
+	start := time.Now()
 	resp, err := c.request(ctx, req, response)
+	c.recordRequest(baseURL+pathObf, resp, time.Since(start), response, err)
 	if err != nil {
 		return nil, err
 	}
 
+	if resp.StatusCode < http.StatusOK || resp.StatusCode >= http.StatusMultipleChoices {
+		statusErr := &StatusError{StatusCode: resp.StatusCode, Status: resp.Status}
+		if response != nil && c.errorBodySize > 0 {
+			statusErr.Body = c.errorBody(response.Data)
+		}
+		return resp, statusErr
+	}
+
+	if response != nil && strings.TrimSpace(response.Data) == "" {
+		if c.Retry.RetryOnEmptyResponse {
+			return resp, ErrEmptyResponse
+		}
+		return resp, nil
+	}
+
+	if response != nil && !c.succeeded(response.Data) {
+		if c.errorBodySize > 0 {
+			return resp, fmt.Errorf("%w: %q", ErrRequestRejected, c.errorBody(response.Data))
+		}
+		return resp, fmt.Errorf("%w: %v", ErrRequestRejected, strings.Fields(response.Data)[0])
+	}
+
 	return resp, nil
 }
 
-func (c *ClientC) newRequest(method, path, pathObf string) (*http.Request, error) {
-	url := c.BaseURL + path
-	urlObf := c.BaseURL + pathObf
+func (c *ClientC) newRequest(ctx context.Context, method, baseURL, path, pathObf string) (*http.Request, error) {
+	url := baseURL + path
+	urlObf := baseURL + pathObf
+
+	klog.V(2).Infof("%sSending %v request to %v", c.logPrefix, method, c.obfuscate(urlObf))
 
-	klog.Infof("Sending request to %v", urlObf)
+	var body io.Reader
+	if method == http.MethodPost {
+		// the parameters move to a form body, away from proxies mishandling
+		// long query strings
+		path, query, _ := strings.Cut(path, "?")
+		url = baseURL + path
+		body = strings.NewReader(query)
+	}
 
-	req, err := http.NewRequest(method, url, nil)
+	req, err := http.NewRequest(method, url, body)
 	if err != nil {
 		return nil, err
 	}
 
 	req.Header = make(http.Header)
-	req.Header.Add("User-Agent", c.UserAgent)
+	req.Header.Add("User-Agent", c.userAgent(ctx))
+	if body != nil {
+		req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	}
+	// duckdns answers in plain text, ask transforming proxies not to encode it
+	req.Header.Set("Accept-Encoding", "identity")
+	for key, values := range c.headerSnapshot() {
+		req.Header[key] = append([]string(nil), values...)
+	}
+
+	if c.RequestSigner != nil {
+		if err := c.RequestSigner(req); err != nil {
+			return nil, fmt.Errorf("unable to sign request: %w", err)
+		}
+	}
 
 	return req, err
 }
@@ -119,14 +589,34 @@ func (c *ClientC) request(ctx context.Context, req *http.Request, response *Resp
 	}
 	req = req.WithContext(ctx)
 
+	if err := c.limiter.wait(ctx); err != nil {
+		return nil, err
+	}
+
+	if c.RequestInterceptor != nil {
+		if err := c.RequestInterceptor(req); err != nil {
+			return nil, fmt.Errorf("request aborted by interceptor: %w", err)
+		}
+	}
+
 	resp, err := c.httpClient.Do(req)
 	if err != nil {
+		var urlErr *neturl.Error
+		if errors.As(err, &urlErr) {
+			urlErr.URL = c.obfuscate(urlErr.URL)
+		}
 		return nil, err
 	}
 	defer resp.Body.Close()
 
 	if response != nil {
-		bytes, err := ioutil.ReadAll(resp.Body)
+		parseRateLimit(resp.Header, response)
+
+		body, err := decodedBody(resp)
+		if err != nil {
+			return resp, err
+		}
+		bytes, err := ioutil.ReadAll(body)
 		if err != nil {
 			return resp, err
 		}
@@ -136,115 +626,428 @@ func (c *ClientC) request(ctx context.Context, req *http.Request, response *Resp
 	return resp, err
 }
 
-// UpdateIP function to update IPv4 and/or without IP address
-func (c *ClientC) UpdateIP(ctx context.Context) (*Response, error) {
-	subdomains := strings.Join(c.Config.DomainNames, ",")
-	url := fmt.Sprintf("%s%s%s%s%s", domainStub, subdomains, tokenStub, c.Config.Token, ip4Stub)
-	urlObf := fmt.Sprintf("%s%s%s%s%s", domainStub, subdomains, tokenStub, "*********", ip4Stub)
+// decodedBody returns the response body, decompressed when a proxy gzipped
+// it despite the identity encoding requested
+func decodedBody(resp *http.Response) (io.Reader, error) {
+	if !strings.EqualFold(resp.Header.Get("Content-Encoding"), "gzip") || resp.Uncompressed {
+		return resp.Body, nil
+	}
+	reader, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("unable to decode gzip response: %w", err)
+	}
+	return reader, nil
+}
 
-	if c.Config.Verbose {
-		url = fmt.Sprintf("%s%s%s", url, verboseStub, strconv.FormatBool(c.Config.Verbose))
-		urlObf = fmt.Sprintf("%s%s%s", urlObf, verboseStub, strconv.FormatBool(c.Config.Verbose))
+// queryOrder is the order in which duckdns parameters are sent, any other
+// parameter follows sorted by name
+var queryOrder = []string{domainsParam, tokenParam, ip4Param, ip6Param, txtParam, clearParam, verboseParam}
+
+// encodeQuery encodes params in queryOrder, commas and the domain separator
+// of the domains list are left unescaped so that it reads as duckdns
+// documents it. Every other reserved character, e.g. '&', '=', '#' or a space
+// in a TXT value, is escaped so that duckdns decodes the exact value; a comma
+// needs no escaping outside the domains list.
+func encodeQuery(params neturl.Values, sep string) string {
+	keys := make([]string, 0, len(params))
+	for _, key := range queryOrder {
+		if _, ok := params[key]; ok {
+			keys = append(keys, key)
+		}
 	}
+	extra := make([]string, 0)
+	for key := range params {
+		if !slices.Contains(queryOrder, key) {
+			extra = append(extra, key)
+		}
+	}
+	sort.Strings(extra)
+	keys = append(keys, extra...)
 
-	response := &Response{}
-	resp, err := c.makeGetRequest(ctx, url, urlObf, response)
+	var b strings.Builder
+	for _, key := range keys {
+		for _, value := range params[key] {
+			if b.Len() > 0 {
+				b.WriteByte('&')
+			}
+			b.WriteString(neturl.QueryEscape(key))
+			b.WriteByte('=')
+			escaped := strings.ReplaceAll(neturl.QueryEscape(value), "%2C", ",")
+			if key == domainsParam && sep != "" {
+				escaped = strings.ReplaceAll(escaped, neturl.QueryEscape(sep), sep)
+			}
+			b.WriteString(escaped)
+		}
+	}
+	return b.String()
+}
 
+// Do function to send an update request with the given parameters and return
+// both the raw http response and the parsed response. The domains default to
+// Config.DomainNames, the token and verbose flag are always set by the client.
+func (c *ClientC) Do(ctx context.Context, params neturl.Values) (*http.Response, *Response, error) {
+	path, pathObf, err := c.buildQuery(ctx, params)
 	if err != nil {
-		return response, err
+		return nil, &Response{}, err
 	}
 
-	response.HTTPResponse = resp
-	return response, err
+	response := &Response{}
+	resp, err := c.makeRequest(ctx, c.Config.method(params), path, pathObf, response)
+	if err != nil {
+		klog.ErrorS(err, c.logPrefix+"Request to duckdns failed", "url", c.obfuscate(c.BaseURL+pathObf))
+	} else {
+		klog.V(4).Infof("%sRequest to %v succeeded", c.logPrefix, c.obfuscate(c.BaseURL+pathObf))
+	}
+
+	return resp, response, err
+}
+
+// buildQuery returns the update path for the operation specific params, and
+// the same path with the token masked for logging. It sets the domains unless
+// params carries its own, the token, and the verbose flag. It fails when the
+// token was emptied after the client was built.
+func (c *ClientC) buildQuery(ctx context.Context, params neturl.Values) (string, string, error) {
+	token := c.token()
+
+	query := make(neturl.Values, len(params)+3)
+	for key, values := range params {
+		query[key] = append([]string(nil), values...)
+	}
+	if _, ok := query[domainsParam]; !ok {
+		query.Set(domainsParam, c.joinDomains(c.Config.DomainNames))
+	}
+	if c.verbose(ctx) {
+		query.Set(verboseParam, "true")
+	}
+
+	query.Set(tokenParam, token)
+	path := updatePath + "?" + encodeQuery(query, c.domainSeparator)
+
+	query.Set(tokenParam, tokenSentinel)
+	pathObf := updatePath + "?" + strings.Replace(encodeQuery(query, c.domainSeparator),
+		tokenParam+"="+tokenSentinel, tokenParam+"="+obfuscatedToken, 1)
+
+	if token == "" {
+		return path, pathObf, ErrEmptyToken
+	}
+	return path, pathObf, nil
+}
+
+// ObfuscatedURL function to return the request url built for params, the
+// same way the update methods build it, with the token masked
+func (c *ClientC) ObfuscatedURL(params neturl.Values) string {
+	_, pathObf, _ := c.buildQuery(context.Background(), params)
+	return c.BaseURL + pathObf
+}
+
+// buildCanonicalQuery returns the query of the update request built for
+// params in its canonical form, see canonicalQuery
+func (c *ClientC) buildCanonicalQuery(params neturl.Values) string {
+	path, _, _ := c.buildQuery(context.Background(), params)
+	_, query, _ := strings.Cut(path, "?")
+	return canonicalQuery(query)
+}
+
+// canonicalQuery returns the raw query with its pairs sorted and the token
+// masked, the pairs are kept as sent to give a stable form to compare the
+// requests of every operation against golden files
+func canonicalQuery(query string) string {
+	pairs := strings.Split(query, "&")
+	for i, pair := range pairs {
+		if key, _, _ := strings.Cut(pair, "="); key == tokenParam {
+			pairs[i] = tokenParam + "=" + obfuscatedToken
+		}
+	}
+	sort.Strings(pairs)
+	return strings.Join(pairs, "&")
+}
+
+// UpdateIP function to update IPv4 and/or without IP address
+func (c *ClientC) UpdateIP(ctx context.Context) (*Response, error) {
+	_, resp, err := c.Do(ctx, neturl.Values{ip4Param: {""}})
+	return resp, err
 }
 
 // UpdateIPWithValues to update IPv4 and/or with IP address
 func (c *ClientC) UpdateIPWithValues(ctx context.Context, ipv4, ipv6 string) (*Response, error) {
-	subdomains := strings.Join(c.Config.DomainNames, ",")
-	url := fmt.Sprintf("%s%s%s%s%s", domainStub, subdomains, tokenStub, c.Config.Token, ip4Stub)
-	urlObf := fmt.Sprintf("%s%s%s%s%s", domainStub, subdomains, tokenStub, "*********", ip4Stub)
-
-	if ipv6 == "" {
-		url = fmt.Sprintf("%s%s", url, ipv4)
-		urlObf = fmt.Sprintf("%s%s", urlObf, ipv4)
-	} else {
-		url = fmt.Sprintf("%s%s%s%s", url, ipv4, ip6Stub, ipv6)
-		urlObf = fmt.Sprintf("%s%s%s%s", urlObf, ipv4, ip6Stub, ipv6)
+	params := neturl.Values{ip4Param: {ipv4}}
+	if ipv6 != "" {
+		if err := validateIPv6(ipv6); err != nil {
+			return &Response{}, err
+		}
+		params.Set(ip6Param, ipv6)
 	}
 
-	if c.Config.Verbose {
-		url = fmt.Sprintf("%s%s%s", url, verboseStub, strconv.FormatBool(c.Config.Verbose))
-		urlObf = fmt.Sprintf("%s%s%s", urlObf, verboseStub, strconv.FormatBool(c.Config.Verbose))
+	_, resp, err := c.Do(ctx, params)
+	return resp, err
+}
+
+// validateIPv6 checks that an address is an IPv6 address duckdns can publish,
+// zoned, link-local, loopback, multicast and unspecified addresses are only
+// meaningful on the local host or link
+func validateIPv6(ipv6 string) error {
+	addr, err := netip.ParseAddr(ipv6)
+	if err != nil {
+		return fmt.Errorf("invalid ipv6 address %q: %w", ipv6, err)
 	}
 
-	resp := &Response{}
-	_, err := c.makeGetRequest(ctx, url, urlObf, resp)
+	switch {
+	case !addr.Is6() || addr.Is4In6():
+		return fmt.Errorf("%q is not an ipv6 address", ipv6)
+	case addr.Zone() != "":
+		return fmt.Errorf("ipv6 address %q has a zone, which duckdns can't publish", ipv6)
+	case addr.IsLinkLocalUnicast():
+		return fmt.Errorf("ipv6 address %q is link-local", ipv6)
+	case addr.IsLoopback():
+		return fmt.Errorf("ipv6 address %q is loopback", ipv6)
+	case addr.IsMulticast():
+		return fmt.Errorf("ipv6 address %q is multicast", ipv6)
+	case addr.IsUnspecified():
+		return fmt.Errorf("ipv6 address %q is unspecified", ipv6)
+	}
+	return nil
+}
 
+// UpdateIPAutoDualStack function to let duckdns record both IPv4 and IPv6
+// from the source address of the request, by sending empty ip and ipv6 values.
+// duckdns can only record the address family the request arrives over, so a
+// dual-stack result needs the request to reach duckdns over each family, e.g.
+// one call from a client built WithIPFamily("tcp4") and one WithIPFamily("tcp6")
+func (c *ClientC) UpdateIPAutoDualStack(ctx context.Context) (*Response, error) {
+	_, resp, err := c.Do(ctx, neturl.Values{ip4Param: {""}, ip6Param: {""}})
 	return resp, err
 }
 
 // ClearIP function that clears the IP from duckdns system
 func (c *ClientC) ClearIP(ctx context.Context) (*Response, error) {
-	subdomains := strings.Join(c.Config.DomainNames, ",")
-	url := fmt.Sprintf("%s%s%s%s%s%s", domainStub, subdomains, tokenStub, c.Config.Token, clearStub, "true")
-	urlObf := fmt.Sprintf("%s%s%s%s%s%s", domainStub, subdomains, tokenStub, "*********", clearStub, "true")
+	_, resp, err := c.Do(ctx, neturl.Values{clearParam: {"true"}})
+	return resp, err
+}
 
-	if c.Config.Verbose {
-		url = fmt.Sprintf("%s%s%s", url, verboseStub, strconv.FormatBool(c.Config.Verbose))
-		urlObf = fmt.Sprintf("%s%s%s", urlObf, verboseStub, strconv.FormatBool(c.Config.Verbose))
+// UpdateRecord function to update TXT record, duckdns applies the same value
+// to every configured domain so prefer UpdateRecordForDomain with several.
+// The record may hold any text, reserved url characters included.
+func (c *ClientC) UpdateRecord(ctx context.Context, record string) (*Response, error) {
+	if len(c.Config.DomainNames) > 1 {
+		klog.Warningf("%sUpdating txt record of %d domains %v at once, their previous values are overwritten", c.logPrefix, len(c.Config.DomainNames), c.Config.DomainNames)
 	}
+	return c.updateRecord(ctx, c.Config.DomainNames, record)
+}
 
-	resp := &Response{}
-	_, err := c.makeGetRequest(ctx, url, urlObf, resp)
+// UpdateRecordForDomain function to update TXT record of a single domain,
+// leaving the other configured domains untouched
+func (c *ClientC) UpdateRecordForDomain(ctx context.Context, domain, record string) (*Response, error) {
+	return c.UpdateRecordDomains(ctx, []string{domain}, record)
+}
 
-	return resp, err
+// UpdateRecordDomains function to update TXT record of the given domains for
+// this call only, Config.DomainNames is left unchanged
+func (c *ClientC) UpdateRecordDomains(ctx context.Context, domains []string, record string) (*Response, error) {
+	if err := validateDomains(domains, c.domainSeparator); err != nil {
+		return &Response{}, err
+	}
+	return c.updateRecord(ctx, domains, record)
 }
 
-// UpdateRecord function to update TXT record
-func (c *ClientC) UpdateRecord(ctx context.Context, record string) (*Response, error) {
-	subdomains := strings.Join(c.Config.DomainNames, ",")
-	url := fmt.Sprintf("%s%s%s%s%s%s", domainStub, subdomains, tokenStub, c.Config.Token, txtStub, record)
-	urlObf := fmt.Sprintf("%s%s%s%s%s%s", domainStub, subdomains, tokenStub, "*********", txtStub, record)
+// joinDomains joins domains into the domains parameter of a request, sorted
+// and deduplicated with WithSortedDomains
+func (c *ClientC) joinDomains(domains []string) string {
+	sep := c.domainSeparator
+	if sep == "" {
+		sep = defaultDomainSeparator
+	}
+	if c.sortDomains {
+		domains = slices.Clone(domains)
+		slices.Sort(domains)
+		domains = slices.Compact(domains)
+	}
+	return strings.Join(domains, sep)
+}
+
+// validateDomains checks a per-call list of domains, none of them may hold
+// the domain separator sep or a character special in a query string
+func validateDomains(domains []string, sep string) error {
+	if len(domains) == 0 {
+		return errors.New("at least one domain is required")
+	}
+	for _, domain := range domains {
+		if domain == "" || strings.ContainsAny(domain, ",&=?# ") || (sep != "" && strings.Contains(domain, sep)) {
+			return fmt.Errorf("invalid domain %q", domain)
+		}
+	}
+	return nil
+}
+
+func (c *ClientC) updateRecord(ctx context.Context, domains []string, record string) (*Response, error) {
+	if c.validateChallengeToken {
+		if err := ValidateChallengeToken(record); err != nil {
+			return &Response{}, err
+		}
+	}
+
+	if c.warnOverwrite {
+		c.warnTXTOverwrite(ctx, domains, record)
+	}
+
+	var previous map[string]string
+	if c.capturePrevious {
+		previous = c.previousTXT(ctx, domains)
+	}
+
+	size := c.maxDomainsPerRequest
+	if size <= 0 {
+		size = defaultMaxDomainsPerRequest
+	}
+
+	var resp *Response
+	var err error
+	if len(domains) <= size {
+		resp, err = c.updateRecordChunk(ctx, domains, record)
+	} else {
+		resp, err = c.updateRecordChunks(ctx, domains, record, size)
+	}
+	if resp != nil {
+		resp.PreviousTXT = previous
+	}
+	if err != nil || c.PostUpdateVerifier == nil {
+		return resp, err
+	}
+
+	for _, domain := range domains {
+		if err := c.PostUpdateVerifier(ctx, domain, record); err != nil {
+			return resp, fmt.Errorf("post update verification of %v failed: %w", domain, err)
+		}
+	}
+	return resp, nil
+}
+
+// updateRecordChunks sends the TXT update of domains in requests of at most
+// size domains and collects their errors in a MultiError
+func (c *ClientC) updateRecordChunks(ctx context.Context, domains []string, record string, size int) (*Response, error) {
+	var resp *Response
+	var errs MultiError
+	for start := 0; start < len(domains); start += size {
+		chunk := domains[start:min(start+size, len(domains))]
 
-	if c.Config.Verbose {
-		url = fmt.Sprintf("%s%s%s", url, verboseStub, strconv.FormatBool(c.Config.Verbose))
-		urlObf = fmt.Sprintf("%s%s%s", urlObf, verboseStub, strconv.FormatBool(c.Config.Verbose))
+		var err error
+		resp, err = c.updateRecordChunk(ctx, chunk, record)
+		errs.add(c.joinDomains(chunk), err)
+	}
+	return resp, errs.errOrNil()
+}
+
+// updateRecordChunk sends the TXT update of domains as a single request
+func (c *ClientC) updateRecordChunk(ctx context.Context, domains []string, record string) (*Response, error) {
+	subdomains := c.joinDomains(domains)
+	params := neturl.Values{
+		domainsParam: {subdomains},
+		txtParam:     {record},
+	}
+
+	if c.superseder != nil {
+		var done func()
+		ctx, done = c.superseder.start(ctx, domains)
+		defer done()
 	}
 
 	resp := &Response{}
-	_, err := c.makeGetRequest(ctx, url, urlObf, resp)
+	unlock, err := c.domainLocks.lock(ctx, domains)
+	if err == nil {
+		defer unlock()
+		_, resp, err = c.Do(ctx, params)
+	}
 
+	if err != nil && errors.Is(context.Cause(ctx), ErrSuperseded) {
+		klog.Infof("%sTxt record update of %v superseded by a newer update", c.logPrefix, subdomains)
+		return resp, ErrSuperseded
+	}
 	return resp, err
 }
 
 // ClearRecord function to clear TXT record
 func (c *ClientC) ClearRecord(ctx context.Context, record string) (*Response, error) {
-	subdomains := strings.Join(c.Config.DomainNames, ",")
-	url := fmt.Sprintf("%s%s%s%s%s%s%s%s", domainStub, subdomains, tokenStub, c.Config.Token, txtStub, record, clearStub, "true")
-	urlObf := fmt.Sprintf("%s%s%s%s%s%s%s%s", domainStub, subdomains, tokenStub, "*********", txtStub, record, clearStub, "true")
+	return c.clearRecord(ctx, c.Config.DomainNames, record)
+}
+
+// ClearRecordDomains function to clear TXT record of the given domains for
+// this call only, Config.DomainNames is left unchanged
+func (c *ClientC) ClearRecordDomains(ctx context.Context, domains []string, record string) (*Response, error) {
+	if err := validateDomains(domains, c.domainSeparator); err != nil {
+		return &Response{}, err
+	}
+	return c.clearRecord(ctx, domains, record)
+}
 
-	if c.Config.Verbose {
-		url = fmt.Sprintf("%s%s%s", url, verboseStub, strconv.FormatBool(c.Config.Verbose))
-		urlObf = fmt.Sprintf("%s%s%s", urlObf, verboseStub, strconv.FormatBool(c.Config.Verbose))
+func (c *ClientC) clearRecord(ctx context.Context, domains []string, record string) (*Response, error) {
+	params := neturl.Values{
+		domainsParam: {c.joinDomains(domains)},
+		txtParam:     {record},
+		clearParam:   {"true"},
 	}
 
-	resp := &Response{}
-	_, err := c.makeGetRequest(ctx, url, urlObf, resp)
+	unlock, err := c.domainLocks.lock(ctx, domains)
+	if err != nil {
+		return &Response{}, err
+	}
+	defer unlock()
 
+	_, resp, err := c.Do(ctx, params)
 	return resp, err
 }
 
+// ErrClearAllNotConfirmed is returned by ClearAll called without confirmation
+var ErrClearAllNotConfirmed = errors.New("clear all requires explicit confirmation")
+
+// ClearAll function to reset the configured domains by clearing both their
+// IPv4/IPv6 addresses and their TXT record, duckdns needs one request for
+// each. This is destructive, the domains stop resolving until updated again,
+// so confirm must be true for anything to be sent.
+func (c *ClientC) ClearAll(ctx context.Context, confirm bool) error {
+	if !confirm {
+		return ErrClearAllNotConfirmed
+	}
+
+	resp, err := c.ClearIP(context.WithValue(ctx, verboseKey{}, true))
+	if err != nil {
+		return fmt.Errorf("unable to clear ip: %w", err)
+	}
+	if result, err := c.parseVerboseResult(resp.Data); err == nil && (result.IPv4 != "" || result.IPv6 != "") {
+		return fmt.Errorf("ip still set after clear: ipv4=%q ipv6=%q", result.IPv4, result.IPv6)
+	}
+
+	if _, err := c.ClearRecord(ctx, ""); err != nil {
+		return fmt.Errorf("unable to clear txt record: %w", err)
+	}
+
+	klog.Infof("%sCleared ip and txt record of %v", c.logPrefix, c.Config.DomainNames)
+	return nil
+}
+
+// dnsName returns the fully qualified duckdns hostname of a domain
+func dnsName(domain string) string {
+	if strings.Contains(domain, "duckdns.org") {
+		return domain
+	}
+	return domain + ".duckdns.org"
+}
+
+// GetRecords function to get all TXT records like dig+ <domain> TXT, values
+// split into several character-strings are joined and unquoted
+func (c *ClientC) GetRecords(ctx context.Context) ([]string, error) {
+	subdomains := dnsName(c.Config.DomainNames[0])
+	txt, err := c.lookupTXT(ctx, subdomains)
+	if err != nil {
+		return nil, fmt.Errorf("unable to get txt record, %v", err)
+	}
+
+	return txt, nil
+}
+
 // GetRecord function to get TXT record like dig+ <domain> TXT
 func (c *ClientC) GetRecord() (string, error) {
-	var subdomains string
-	if strings.Contains(c.Config.DomainNames[0], "duckdns.org") {
-		subdomains = c.Config.DomainNames[0]
-	} else {
-		subdomains = c.Config.DomainNames[0] + ".duckdns.org"
-	}
-	txt, err := net.LookupTXT(subdomains)
+	txt, err := c.GetRecords(context.Background())
 	if err != nil {
-		return "", fmt.Errorf("unable to get txt record, %v", err)
+		return "", err
 	}
 
 	if len(txt) == 0 {
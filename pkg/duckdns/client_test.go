@@ -0,0 +1,951 @@
+package duckdns
+
+import (
+	"compress/gzip"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	neturl "net/url"
+	"reflect"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestUpdateRecordRetriesEmptyResponse(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(sequence(
+		respond(http.StatusOK, ""),
+		respond(http.StatusOK, "OK"),
+	)))
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if got := log.count(); got != 2 {
+		t.Errorf("requests = %d, want 2", got)
+	}
+}
+
+func TestUpdateRecordEmptyResponsePersists(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, " \n")))
+
+	_, err := c.UpdateRecord(context.Background(), "value")
+	if !errors.Is(err, ErrEmptyResponse) {
+		t.Fatalf("UpdateRecord() error = %v, want %v", err, ErrEmptyResponse)
+	}
+	if got, want := log.count(), c.Retry.MaxAttempts; got != want {
+		t.Errorf("requests = %d, want %d", got, want)
+	}
+}
+
+func TestUpdateRecordEmptyResponseDisabled(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "")), WithRetryOnEmptyResponse(false))
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if got := log.count(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestUpdateRecordEmptyErrorStatus(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusInternalServerError, ""), WithRetryOnEmptyResponse(false))
+
+	_, err := c.UpdateRecord(context.Background(), "value")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusInternalServerError {
+		t.Fatalf("UpdateRecord() error = %v, want a 500 StatusError", err)
+	}
+}
+
+func TestObfuscateEveryOccurrence(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+
+	url := "https://proxy.example/" + testToken + "/update?token=" + testToken + "&domains=example"
+	got := c.obfuscate(url)
+	if strings.Contains(got, testToken) {
+		t.Fatalf("obfuscate() = %q, token not masked", got)
+	}
+	if want := "https://proxy.example/" + obfuscatedToken + "/update?token=" + obfuscatedToken + "&domains=example"; got != want {
+		t.Errorf("obfuscate() = %q, want %q", got, want)
+	}
+}
+
+func TestObfuscatedURLPlaceholder(t *testing.T) {
+	logs := captureLogs(t, 2)
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithRequestRecorder(1))
+
+	want := "token=" + obfuscatedToken + "&txt=value"
+	if got := c.ObfuscatedURL(neturl.Values{"txt": {"value"}}); !strings.HasSuffix(got, want) {
+		t.Errorf("ObfuscatedURL() = %q, want the literal placeholder %q", got, want)
+	}
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if got := logs.lines("Sending GET request"); len(got) != 1 || !strings.Contains(got[0], want) {
+		t.Errorf("logged %q, want the literal placeholder %q", got, want)
+	}
+	if got := c.RecentRequests()[0].URL; !strings.HasSuffix(got, want) {
+		t.Errorf("recorded URL = %q, want the literal placeholder %q", got, want)
+	}
+}
+
+func TestRequestErrorMasksToken(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+	c.BaseURL = "http://127.0.0.1:1"
+	c.Retry.MaxAttempts = 1
+
+	_, err := c.UpdateRecord(context.Background(), "value")
+	if err == nil {
+		t.Fatal("UpdateRecord() error = nil, want a connection error")
+	}
+	if strings.Contains(err.Error(), testToken) {
+		t.Errorf("UpdateRecord() error = %q, token not masked", err)
+	}
+}
+
+func TestUpdateIPAutoDualStack(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")))
+
+	if _, err := c.UpdateIPAutoDualStack(context.Background()); err != nil {
+		t.Fatalf("UpdateIPAutoDualStack() error = %v", err)
+	}
+
+	query := log.last(t).URL.Query()
+	for _, param := range []string{"ip", "ipv6"} {
+		if values, ok := query[param]; !ok || len(values) != 1 || values[0] != "" {
+			t.Errorf("%s = %q, want a single empty value", param, values)
+		}
+	}
+}
+
+func TestSuccessDetection(t *testing.T) {
+	tests := []struct {
+		name         string
+		status       int
+		body         string
+		wantRejected bool
+		wantStatus   bool
+	}{
+		{name: "ok", status: http.StatusOK, body: "OK"},
+		{name: "lowercase", status: http.StatusOK, body: "ok"},
+		{name: "mixed case", status: http.StatusOK, body: "Ok"},
+		{name: "trailing data", status: http.StatusOK, body: "OK NOCHANGE"},
+		{name: "verbose", status: http.StatusOK, body: "OK\n203.0.113.7\n\nNOCHANGE"},
+		{name: "leading space", status: http.StatusOK, body: "  OK\n"},
+		{name: "created", status: http.StatusCreated, body: "OK"},
+		{name: "ko", status: http.StatusOK, body: "KO", wantRejected: true},
+		{name: "ok prefix", status: http.StatusOK, body: "OKAY", wantRejected: true},
+		{name: "ok from a failing proxy", status: http.StatusServiceUnavailable, body: "OK", wantStatus: true},
+		{name: "ok from a redirect", status: http.StatusMultipleChoices, body: "OK", wantStatus: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, newTestConfig(), respond(tt.status, tt.body))
+			c.Retry.MaxAttempts = 1
+			c.Retry.ServerErrorRetries = 0
+
+			_, err := c.UpdateRecord(context.Background(), "value")
+
+			var statusErr *StatusError
+			switch {
+			case tt.wantRejected:
+				if !errors.Is(err, ErrRequestRejected) {
+					t.Errorf("UpdateRecord() error = %v, want %v", err, ErrRequestRejected)
+				}
+			case tt.wantStatus:
+				if !errors.As(err, &statusErr) || statusErr.StatusCode != tt.status || errors.Is(err, ErrRequestRejected) {
+					t.Errorf("UpdateRecord() error = %v, want a %d StatusError", err, tt.status)
+				}
+			case err != nil:
+				t.Errorf("UpdateRecord() error = %v", err)
+			}
+		})
+	}
+}
+
+func TestSuccessMatcherOverride(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "NOERROR"))
+	c.SuccessMatcher = func(body string) bool {
+		return strings.HasPrefix(body, "NOERROR")
+	}
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Errorf("UpdateRecord() error = %v", err)
+	}
+}
+
+func TestSuccessMatcherNotAppliedToErrorStatus(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusBadRequest, "NOERROR"))
+	c.SuccessMatcher = func(string) bool { return true }
+
+	var statusErr *StatusError
+	if _, err := c.UpdateRecord(context.Background(), "value"); !errors.As(err, &statusErr) {
+		t.Errorf("UpdateRecord() error = %v, want a StatusError", err)
+	}
+}
+
+func TestDo(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig("first", "second"), log.wrap(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Test", "raw")
+		w.Write([]byte("OK"))
+	}))
+
+	resp, response, err := c.Do(context.Background(), neturl.Values{"txt": {"value"}})
+	if err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if resp.StatusCode != http.StatusOK || resp.Header.Get("X-Test") != "raw" {
+		t.Errorf("Do() raw response = %v %v, want the server response", resp.Status, resp.Header)
+	}
+	if response.Data != "OK" || response.HTTPResponse != resp {
+		t.Errorf("Do() response = %+v, want OK with the raw response", response)
+	}
+
+	query := log.last(t).URL.Query()
+	want := neturl.Values{"domains": {"first,second"}, "token": {testToken}, "txt": {"value"}}
+	if !reflect.DeepEqual(query, want) {
+		t.Errorf("query = %v, want %v", query, want)
+	}
+}
+
+func TestDoDomainsOverride(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig("first", "second"), log.wrap(respond(http.StatusOK, "OK")))
+
+	if _, _, err := c.Do(context.Background(), neturl.Values{"domains": {"other"}, "ip": {""}}); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	if got := log.last(t).URL.Query().Get("domains"); got != "other" {
+		t.Errorf("domains = %q, want other", got)
+	}
+}
+
+func TestDoRejected(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "KO"))
+
+	resp, response, err := c.Do(context.Background(), neturl.Values{"ip": {""}})
+	if !errors.Is(err, ErrRequestRejected) {
+		t.Fatalf("Do() error = %v, want %v", err, ErrRequestRejected)
+	}
+	if resp == nil || response.Data != "KO" {
+		t.Errorf("Do() = %v, %+v, want the KO response alongside the error", resp, response)
+	}
+}
+
+func TestUpdateMethodQueries(t *testing.T) {
+	tests := []struct {
+		name string
+		call func(c *ClientC) error
+		want string
+	}{
+		{
+			name: "UpdateIP",
+			call: func(c *ClientC) error { _, err := c.UpdateIP(context.Background()); return err },
+			want: "domains=first,second&token=" + testToken + "&ip=",
+		},
+		{
+			name: "UpdateIPWithValues ipv4",
+			call: func(c *ClientC) error {
+				_, err := c.UpdateIPWithValues(context.Background(), "203.0.113.7", "")
+				return err
+			},
+			want: "domains=first,second&token=" + testToken + "&ip=203.0.113.7",
+		},
+		{
+			name: "UpdateIPWithValues ipv4 and ipv6",
+			call: func(c *ClientC) error {
+				_, err := c.UpdateIPWithValues(context.Background(), "203.0.113.7", "2001:db8::1")
+				return err
+			},
+			want: "domains=first,second&token=" + testToken + "&ip=203.0.113.7&ipv6=2001%3Adb8%3A%3A1",
+		},
+		{
+			name: "ClearIP",
+			call: func(c *ClientC) error { _, err := c.ClearIP(context.Background()); return err },
+			want: "domains=first,second&token=" + testToken + "&clear=true",
+		},
+		{
+			name: "UpdateRecord",
+			call: func(c *ClientC) error { _, err := c.UpdateRecord(context.Background(), "value"); return err },
+			want: "domains=first,second&token=" + testToken + "&txt=value",
+		},
+		{
+			name: "ClearRecord",
+			call: func(c *ClientC) error { _, err := c.ClearRecord(context.Background(), "value"); return err },
+			want: "domains=first,second&token=" + testToken + "&txt=value&clear=true",
+		},
+	}
+
+	for _, tt := range tests {
+		for _, verbose := range []bool{false, true} {
+			t.Run(tt.name+"/verbose="+strconv.FormatBool(verbose), func(t *testing.T) {
+				var log requestLog
+				config := newTestConfig("first", "second")
+				config.Verbose = verbose
+				c := newTestClient(t, config, log.wrap(respond(http.StatusOK, "OK")))
+
+				if err := tt.call(c); err != nil {
+					t.Fatalf("%s error = %v", tt.name, err)
+				}
+
+				want := tt.want
+				if verbose {
+					want += "&verbose=true"
+				}
+				if got := log.last(t).URL.RawQuery; got != want {
+					t.Errorf("query = %q, want %q", got, want)
+				}
+			})
+		}
+	}
+}
+
+func TestMethodPerOperation(t *testing.T) {
+	methods := map[Operation]string{OperationTXT: http.MethodPost}
+
+	tests := []struct {
+		name       string
+		call       func(c *ClientC) error
+		wantMethod string
+		wantParams string
+	}{
+		{
+			name:       "UpdateRecord",
+			call:       func(c *ClientC) error { _, err := c.UpdateRecord(context.Background(), "value"); return err },
+			wantMethod: http.MethodPost,
+			wantParams: "domains=example&token=" + testToken + "&txt=value",
+		},
+		{
+			name:       "UpdateIP",
+			call:       func(c *ClientC) error { _, err := c.UpdateIP(context.Background()); return err },
+			wantMethod: http.MethodGet,
+			wantParams: "domains=example&token=" + testToken + "&ip=",
+		},
+		{
+			name:       "ClearRecord",
+			call:       func(c *ClientC) error { _, err := c.ClearRecord(context.Background(), "value"); return err },
+			wantMethod: http.MethodGet,
+			wantParams: "domains=example&token=" + testToken + "&txt=value&clear=true",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log requestLog
+			config := newTestConfig()
+			config.Methods = methods
+			c := newTestClient(t, config, log.wrap(respond(http.StatusOK, "OK")))
+
+			if err := tt.call(c); err != nil {
+				t.Fatalf("%s error = %v", tt.name, err)
+			}
+
+			req := log.last(t)
+			if req.Method != tt.wantMethod {
+				t.Errorf("method = %s, want %s", req.Method, tt.wantMethod)
+			}
+			query, body := req.URL.RawQuery, log.bodies[0]
+			if tt.wantMethod == http.MethodPost {
+				query, body = body, query
+				if got := req.Header.Get("Content-Type"); got != "application/x-www-form-urlencoded" {
+					t.Errorf("Content-Type = %q, want a form body", got)
+				}
+			}
+			if query != tt.wantParams {
+				t.Errorf("parameters = %q, want %q", query, tt.wantParams)
+			}
+			if body != "" {
+				t.Errorf("parameters also sent as %q", body)
+			}
+		})
+	}
+}
+
+func TestValidateMethods(t *testing.T) {
+	for _, method := range []string{http.MethodGet, http.MethodPost, http.MethodPut} {
+		config := newTestConfig()
+		config.Methods = map[Operation]string{OperationIP: method}
+		err := config.Validate()
+		if wantErr := method == http.MethodPut; (err != nil) != wantErr {
+			t.Errorf("Validate() with %s error = %v, want error %v", method, err, wantErr)
+		}
+	}
+}
+
+func TestUpdateRecordReservedCharacters(t *testing.T) {
+	values := []string{
+		"a&b=c#d e",
+		"token=x&domains=other&clear=true",
+		"#fragment",
+		"plus+percent%20comma,semicolon;",
+		"quote'double\"slash/question?",
+		"ünïcödé",
+	}
+
+	for _, method := range []string{http.MethodGet, http.MethodPost} {
+		for _, value := range values {
+			t.Run(method+"/"+value, func(t *testing.T) {
+				config := newTestConfig()
+				config.Methods = map[Operation]string{OperationTXT: method}
+				var log requestLog
+				c := newTestClient(t, config, log.wrap(respond(http.StatusOK, "OK")))
+
+				if _, err := c.UpdateRecord(context.Background(), value); err != nil {
+					t.Fatalf("UpdateRecord() error = %v", err)
+				}
+
+				raw := log.last(t).URL.RawQuery
+				if method == http.MethodPost {
+					raw = log.bodies[0]
+				}
+				received, err := neturl.ParseQuery(raw)
+				if err != nil {
+					t.Fatalf("ParseQuery(%q) error = %v", raw, err)
+				}
+				want := neturl.Values{"domains": {"example"}, "token": {testToken}, "txt": {value}}
+				if !reflect.DeepEqual(received, want) {
+					t.Errorf("received parameters %v, want %v", received, want)
+				}
+			})
+		}
+	}
+}
+
+func TestUpdateRecordForDomain(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig("first", "second", "third"), log.wrap(respond(http.StatusOK, "OK")))
+
+	if _, err := c.UpdateRecordForDomain(context.Background(), "second", "value"); err != nil {
+		t.Fatalf("UpdateRecordForDomain() error = %v", err)
+	}
+
+	query := log.last(t).URL.Query()
+	if got := query["domains"]; len(got) != 1 || got[0] != "second" {
+		t.Errorf("domains = %q, want [second]", got)
+	}
+	if got := query.Get("txt"); got != "value" {
+		t.Errorf("txt = %q, want value", got)
+	}
+}
+
+func TestRateLimitHeaders(t *testing.T) {
+	reset := time.Date(2030, 1, 2, 3, 4, 5, 0, time.UTC)
+	c := newTestClient(t, newTestConfig(), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "42")
+		w.Header().Set("X-RateLimit-Reset", strconv.FormatInt(reset.Unix(), 10))
+		w.Write([]byte("OK"))
+	})
+
+	resp, err := c.UpdateRecord(context.Background(), "value")
+	if err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if resp.RateLimitRemaining != 42 {
+		t.Errorf("RateLimitRemaining = %d, want 42", resp.RateLimitRemaining)
+	}
+	if !resp.RateLimitReset.Equal(reset) {
+		t.Errorf("RateLimitReset = %v, want %v", resp.RateLimitReset, reset)
+	}
+}
+
+func TestRateLimitHeadersRelativeReset(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-RateLimit-Remaining", "0")
+		w.Header().Set("X-RateLimit-Reset", "60")
+		w.Write([]byte("OK"))
+	})
+
+	before := time.Now()
+	resp, err := c.UpdateRecord(context.Background(), "value")
+	if err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if resp.RateLimitReset.Before(before.Add(time.Minute)) || resp.RateLimitReset.After(time.Now().Add(time.Minute)) {
+		t.Errorf("RateLimitReset = %v, want a minute from now", resp.RateLimitReset)
+	}
+}
+
+func TestRateLimitHeadersAbsent(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+
+	resp, err := c.UpdateRecord(context.Background(), "value")
+	if err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if resp.RateLimitRemaining != 0 || !resp.RateLimitReset.IsZero() {
+		t.Errorf("rate limit = %d, %v, want zero values", resp.RateLimitRemaining, resp.RateLimitReset)
+	}
+}
+
+func TestFallbackBaseURL(t *testing.T) {
+	var fallback requestLog
+	srv := httptest.NewServer(fallback.wrap(respond(http.StatusOK, "OK")))
+	t.Cleanup(srv.Close)
+
+	var primary requestLog
+	c := newTestClient(t, newTestConfig(), primary.wrap(respond(http.StatusServiceUnavailable, "")),
+		WithFallbackBaseURL(srv.URL+"/"))
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if got := primary.count(); got != 3 {
+		t.Errorf("primary requests = %d, want 3", got)
+	}
+	if got := fallback.count(); got != 1 {
+		t.Errorf("fallback requests = %d, want 1", got)
+	}
+	if got := fallback.last(t).URL.Query().Get("txt"); got != "value" {
+		t.Errorf("fallback txt = %q, want value", got)
+	}
+}
+
+func TestFallbackBaseURLSkippedOnRejection(t *testing.T) {
+	var fallback requestLog
+	srv := httptest.NewServer(fallback.wrap(respond(http.StatusOK, "OK")))
+	t.Cleanup(srv.Close)
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "KO"), WithFallbackBaseURL(srv.URL))
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); !errors.Is(err, ErrRequestRejected) {
+		t.Fatalf("UpdateRecord() error = %v, want %v", err, ErrRequestRejected)
+	}
+	if got := fallback.count(); got != 0 {
+		t.Errorf("fallback requests = %d, want 0", got)
+	}
+}
+
+func TestWithFallbackBaseURLInvalid(t *testing.T) {
+	for _, rawURL := range []string{"", "ftp://example.org", "https://", "://bad"} {
+		if err := WithFallbackBaseURL(rawURL)(&ClientC{}); err == nil {
+			t.Errorf("WithFallbackBaseURL(%q) error = nil, want an error", rawURL)
+		}
+	}
+}
+
+func TestUpdateRecordDomains(t *testing.T) {
+	var log requestLog
+	config := newTestConfig("configured")
+	c := newTestClient(t, config, log.wrap(respond(http.StatusOK, "OK")))
+
+	if _, err := c.UpdateRecordDomains(context.Background(), []string{"first", "second"}, "value"); err != nil {
+		t.Fatalf("UpdateRecordDomains() error = %v", err)
+	}
+	if got := log.last(t).URL.Query().Get("domains"); got != "first,second" {
+		t.Errorf("domains = %q, want first,second", got)
+	}
+	if !reflect.DeepEqual(config.DomainNames, []string{"configured"}) {
+		t.Errorf("Config.DomainNames = %q, want [configured]", config.DomainNames)
+	}
+}
+
+func TestUpdateRecordDomainsInvalid(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")))
+
+	for _, domains := range [][]string{nil, {""}, {"a,b"}, {"a&b=c"}} {
+		if _, err := c.UpdateRecordDomains(context.Background(), domains, "value"); err == nil {
+			t.Errorf("UpdateRecordDomains(%q) error = nil, want an error", domains)
+		}
+	}
+	if got := log.count(); got != 0 {
+		t.Errorf("requests = %d, want 0", got)
+	}
+}
+
+func TestWarningsSwappedTokenAndDomain(t *testing.T) {
+	tests := []struct {
+		name     string
+		token    string
+		domains  []string
+		wantWarn bool
+	}{
+		{name: "valid", token: testToken, domains: []string{"example"}},
+		{name: "uppercase token", token: strings.ToUpper(testToken), domains: []string{"example"}},
+		{name: "hyphenated domain", token: testToken, domains: []string{"my-home-server"}},
+		{name: "domain of token length", token: testToken, domains: []string{strings.Repeat("a", 36)}},
+		{name: "token is a domain", token: "example.duckdns.org", domains: []string{"example"}, wantWarn: true},
+		{name: "domain is a token", token: "example", domains: []string{testToken}, wantWarn: true},
+		{name: "swapped", token: "example.duckdns.org", domains: []string{testToken}, wantWarn: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := &ConfigC{Token: tt.token, DomainNames: tt.domains}
+			// a heuristic never makes the configuration invalid
+			if err := config.Validate(); err != nil {
+				t.Fatalf("Validate() error = %v", err)
+			}
+			warnings := config.Warnings()
+			if (len(warnings) != 0) != tt.wantWarn {
+				t.Fatalf("Warnings() = %q, want warnings %v", warnings, tt.wantWarn)
+			}
+			for _, warning := range warnings {
+				if !strings.Contains(warning, "swapped") {
+					t.Errorf("warning %q, want it to mention the swap", warning)
+				}
+			}
+		})
+	}
+}
+
+func TestNewClientLogsSwapWarning(t *testing.T) {
+	logs := captureLogs(t, 0)
+
+	config := newTestConfig(testToken)
+	c := newTestClient(t, config, respond(http.StatusOK, "OK"))
+	if c == nil {
+		t.Fatal("NewClient() = nil, want a client despite the warning")
+	}
+	if got := logs.lines("may be swapped"); len(got) != 1 {
+		t.Errorf("logged %q, want a single swap warning", got)
+	}
+}
+
+func TestWithErrorBody(t *testing.T) {
+	body := "KO invalid token " + testToken + " " + strings.Repeat("x", 64)
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, body), WithErrorBody(32))
+
+	_, err := c.UpdateRecord(context.Background(), "value")
+	if !errors.Is(err, ErrRequestRejected) {
+		t.Fatalf("UpdateRecord() error = %v, want %v", err, ErrRequestRejected)
+	}
+	msg := err.Error()
+	if !strings.Contains(msg, "KO invalid token") {
+		t.Errorf("error %q does not hold the body snippet", msg)
+	}
+	if strings.Contains(msg, testToken) {
+		t.Errorf("error %q holds the token", msg)
+	}
+	if strings.Contains(msg, strings.Repeat("x", 16)) || !strings.HasSuffix(msg, `..."`) {
+		t.Errorf("error %q does not truncate the body", msg)
+	}
+}
+
+func TestWithErrorBodyStatusError(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusBadRequest, "bad token "+testToken), WithErrorBody(0))
+
+	_, err := c.UpdateRecord(context.Background(), "value")
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) {
+		t.Fatalf("UpdateRecord() error = %v, want a StatusError", err)
+	}
+	if statusErr.Body != "bad token "+obfuscatedToken {
+		t.Errorf("Body = %q, want the redacted body", statusErr.Body)
+	}
+}
+
+func TestErrorBodyDisabled(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "KO some explanation"))
+
+	_, err := c.UpdateRecord(context.Background(), "value")
+	if err == nil || strings.Contains(err.Error(), "explanation") {
+		t.Errorf("UpdateRecord() error = %v, want the status only", err)
+	}
+}
+
+func TestClearAll(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Has("txt") {
+			w.Write([]byte("OK"))
+			return
+		}
+		w.Write([]byte("OK\n\n\nUPDATED"))
+	}))
+
+	if err := c.ClearAll(context.Background(), true); err != nil {
+		t.Fatalf("ClearAll() error = %v", err)
+	}
+	if got := log.count(); got != 2 {
+		t.Fatalf("requests = %d, want 2", got)
+	}
+
+	ip := log.requests[0].URL.Query()
+	if ip.Get("clear") != "true" || ip.Get("verbose") != "true" || ip.Has("txt") {
+		t.Errorf("ip clear query = %v, want clear and verbose without txt", ip)
+	}
+	txt := log.requests[1].URL.Query()
+	if txt.Get("clear") != "true" || !txt.Has("txt") || txt.Get("domains") != "example" {
+		t.Errorf("txt clear query = %v, want clear with txt", txt)
+	}
+}
+
+func TestClearAllIPStillSet(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK\n203.0.113.7\n\nNOCHANGE")))
+
+	if err := c.ClearAll(context.Background(), true); err == nil || !strings.Contains(err.Error(), "still set") {
+		t.Fatalf("ClearAll() error = %v, want the ip still set", err)
+	}
+	if got := log.count(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestClearAllNotConfirmed(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")))
+
+	if err := c.ClearAll(context.Background(), false); !errors.Is(err, ErrClearAllNotConfirmed) {
+		t.Fatalf("ClearAll() error = %v, want %v", err, ErrClearAllNotConfirmed)
+	}
+	if got := log.count(); got != 0 {
+		t.Errorf("requests = %d, want 0", got)
+	}
+}
+
+func TestParseStatus(t *testing.T) {
+	tests := []struct {
+		body string
+		want Status
+	}{
+		{body: "OK", want: StatusOK},
+		{body: "ok", want: StatusOK},
+		{body: "OK\n203.0.113.7\n\nUPDATED", want: StatusOK},
+		{body: "  OK  ", want: StatusOK},
+		{body: "KO", want: StatusKO},
+		{body: "Ko\n", want: StatusKO},
+		{body: "", want: StatusUnknown},
+		{body: "\n", want: StatusUnknown},
+		{body: "OKAY", want: StatusUnknown},
+		{body: "<html>bad gateway</html>", want: StatusUnknown},
+	}
+
+	for _, tt := range tests {
+		if got := ParseStatus(tt.body); got != tt.want {
+			t.Errorf("ParseStatus(%q) = %q, want %q", tt.body, got, tt.want)
+		}
+	}
+}
+
+func TestObfuscatedURL(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig("first", "second"), log.wrap(respond(http.StatusOK, "OK")))
+
+	params := neturl.Values{"txt": {"a&b"}}
+	got := c.ObfuscatedURL(params)
+	if strings.Contains(got, testToken) {
+		t.Fatalf("ObfuscatedURL() = %q holds the token", got)
+	}
+
+	u, err := neturl.Parse(got)
+	if err != nil {
+		t.Fatalf("ObfuscatedURL() = %q is not a url: %v", got, err)
+	}
+	if base := u.Scheme + "://" + u.Host; base != c.BaseURL || u.Path != "/update" {
+		t.Errorf("ObfuscatedURL() = %q, want %v/update", got, c.BaseURL)
+	}
+	want := neturl.Values{"domains": {"first,second"}, "token": {obfuscatedToken}, "txt": {"a&b"}}
+	if !reflect.DeepEqual(u.Query(), want) {
+		t.Errorf("query = %v, want %v", u.Query(), want)
+	}
+
+	// the url matches the request sent for the same params, token aside
+	if _, _, err := c.Do(context.Background(), params); err != nil {
+		t.Fatalf("Do() error = %v", err)
+	}
+	sent := log.last(t).URL.Query()
+	sent.Set("token", obfuscatedToken)
+	if !reflect.DeepEqual(sent, want) {
+		t.Errorf("sent query = %v, want %v", sent, want)
+	}
+}
+
+// gzipped returns a handler answering body gzip-encoded whatever the request
+// accepts, as a transforming proxy would
+func gzipped(body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Encoding", "gzip")
+		zw := gzip.NewWriter(w)
+		zw.Write([]byte(body))
+		zw.Close()
+	}
+}
+
+func TestGzipResponse(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(gzipped("OK")))
+
+	resp, err := c.UpdateRecord(context.Background(), "value")
+	if err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if resp.Data != "OK" {
+		t.Errorf("Data = %q, want OK", resp.Data)
+	}
+	if got := log.last(t).Header.Get("Accept-Encoding"); got != "identity" {
+		t.Errorf("Accept-Encoding = %q, want identity", got)
+	}
+}
+
+func TestGzipResponseRejected(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), gzipped("KO"))
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); !errors.Is(err, ErrRequestRejected) {
+		t.Fatalf("UpdateRecord() error = %v, want %v", err, ErrRequestRejected)
+	}
+}
+
+func TestUpdateIPWithValuesIPv6(t *testing.T) {
+	tests := []struct {
+		ipv6    string
+		wantErr string
+	}{
+		{ipv6: "2001:db8::1"},
+		{ipv6: "not an address", wantErr: "invalid ipv6 address"},
+		{ipv6: "203.0.113.7", wantErr: "not an ipv6 address"},
+		{ipv6: "::ffff:203.0.113.7", wantErr: "not an ipv6 address"},
+		{ipv6: "fe80::1%eth0", wantErr: "has a zone"},
+		{ipv6: "2001:db8::1%eth0", wantErr: "has a zone"},
+		{ipv6: "fe80::1", wantErr: "link-local"},
+		{ipv6: "::1", wantErr: "loopback"},
+		{ipv6: "ff02::1", wantErr: "multicast"},
+		{ipv6: "::", wantErr: "unspecified"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.ipv6, func(t *testing.T) {
+			var log requestLog
+			c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")))
+
+			_, err := c.UpdateIPWithValues(context.Background(), "203.0.113.7", tt.ipv6)
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("UpdateIPWithValues() error = %v", err)
+				}
+				if got := log.last(t).URL.Query().Get("ipv6"); got != tt.ipv6 {
+					t.Errorf("ipv6 = %q, want %q", got, tt.ipv6)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("UpdateIPWithValues() error = %v, want %q", err, tt.wantErr)
+			}
+			if got := log.count(); got != 0 {
+				t.Errorf("requests = %d, want 0", got)
+			}
+		})
+	}
+}
+
+func TestWithDomainSeparator(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig("first", "second"), log.wrap(respond(http.StatusOK, "OK")), WithDomainSeparator(";"))
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if got := log.last(t).URL.RawQuery; !strings.HasPrefix(got, "domains=first;second&") {
+		t.Errorf("query = %q, want domains=first;second", got)
+	}
+
+	if _, err := c.UpdateRecordDomains(context.Background(), []string{"a;b"}, "value"); err == nil {
+		t.Error("UpdateRecordDomains() of a domain holding the separator error = nil, want an error")
+	}
+	// a comma is rejected whatever the separator
+	if _, err := c.UpdateRecordDomains(context.Background(), []string{"a,b"}, "value"); err == nil {
+		t.Error("UpdateRecordDomains() of a domain holding a comma error = nil, want an error")
+	}
+	if got := log.count(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestWithDomainSeparatorInvalid(t *testing.T) {
+	for _, sep := range []string{"", "&", "=", "#", " ", ";;", "."} {
+		c := &ClientC{Config: newTestConfig()}
+		if err := WithDomainSeparator(sep)(c); err == nil {
+			t.Errorf("WithDomainSeparator(%q) error = nil, want an error", sep)
+		}
+	}
+
+	c := &ClientC{Config: &ConfigC{Token: testToken, DomainNames: []string{"a|b"}}}
+	if err := WithDomainSeparator("|")(c); err == nil {
+		t.Error("WithDomainSeparator() held by a configured domain error = nil, want an error")
+	}
+}
+
+func TestWithSortedDomains(t *testing.T) {
+	configured := []string{"charlie", "alpha", "bravo", "alpha", "charlie"}
+
+	var log requestLog
+	c := newTestClient(t, newTestConfig(configured...), log.wrap(respond(http.StatusOK, "OK")), WithSortedDomains())
+
+	if _, err := c.UpdateIP(context.Background()); err != nil {
+		t.Fatalf("UpdateIP() error = %v", err)
+	}
+	if got := log.last(t).URL.Query().Get("domains"); got != "alpha,bravo,charlie" {
+		t.Errorf("domains = %q, want alpha,bravo,charlie", got)
+	}
+
+	// only the request is sorted, the configuration keeps its first domain
+	want := []string{"charlie", "alpha", "bravo", "alpha", "charlie"}
+	if !reflect.DeepEqual(c.Config.DomainNames, want) || !reflect.DeepEqual(configured, want) {
+		t.Errorf("DomainNames = %v, configured %v, want both left as %v", c.Config.DomainNames, configured, want)
+	}
+}
+
+func TestWithoutSortedDomains(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig("charlie", "alpha"), log.wrap(respond(http.StatusOK, "OK")))
+
+	if _, err := c.UpdateIP(context.Background()); err != nil {
+		t.Fatalf("UpdateIP() error = %v", err)
+	}
+	if got := log.last(t).URL.Query().Get("domains"); got != "charlie,alpha" {
+		t.Errorf("domains = %q, want the configured order", got)
+	}
+}
+
+func TestPostUpdateVerifier(t *testing.T) {
+	c := newTestClient(t, newTestConfig("first", "second"), respond(http.StatusOK, "OK"))
+
+	var verified []string
+	c.PostUpdateVerifier = func(ctx context.Context, domain, value string) error {
+		verified = append(verified, domain+"="+value)
+		return nil
+	}
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if want := []string{"first=value", "second=value"}; !reflect.DeepEqual(verified, want) {
+		t.Errorf("verified = %q, want %q", verified, want)
+	}
+}
+
+func TestPostUpdateVerifierFailure(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+	errMonitor := errors.New("monitoring does not see the value")
+	c.PostUpdateVerifier = func(ctx context.Context, domain, value string) error { return errMonitor }
+
+	_, err := c.UpdateRecord(context.Background(), "value")
+	if !errors.Is(err, errMonitor) || !strings.Contains(err.Error(), "example") {
+		t.Fatalf("UpdateRecord() error = %v, want the verification failure of example", err)
+	}
+}
+
+func TestPostUpdateVerifierSkippedOnFailure(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "KO"))
+	c.PostUpdateVerifier = func(ctx context.Context, domain, value string) error {
+		t.Error("PostUpdateVerifier called for a failed update")
+		return nil
+	}
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); !errors.Is(err, ErrRequestRejected) {
+		t.Fatalf("UpdateRecord() error = %v, want %v", err, ErrRequestRejected)
+	}
+}
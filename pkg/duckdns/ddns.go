@@ -0,0 +1,137 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	neturl "net/url"
+
+	"k8s.io/klog/v2"
+)
+
+// UpdateIPv4FromPublic function to detect the public IPv4 address and update
+// the configured domains only when their published A record differs
+func (c *ClientC) UpdateIPv4FromPublic(ctx context.Context) (bool, error) {
+	detected, err := c.DetectPublicIP(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	ip := net.ParseIP(detected)
+	if ip.To4() == nil {
+		return false, fmt.Errorf("detected public ip %v is not an ipv4 address", detected)
+	}
+
+	current, err := c.publishedIPv4(ctx)
+	if err != nil {
+		return false, err
+	}
+
+	if current.Equal(ip) {
+		klog.Infof("%sPublished ipv4 %v is up to date", c.logPrefix, current)
+		return false, nil
+	}
+
+	klog.Infof("%sPublished ipv4 %v differs from public ipv4 %v, updating", c.logPrefix, current, ip)
+	if _, err := c.UpdateIPWithValues(ctx, ip.String(), ""); err != nil {
+		return false, err
+	}
+
+	return true, nil
+}
+
+// publishedIPv4 returns the A record shared by the configured domains, or nil
+// when the domains don't all resolve to the same address
+func (c *ClientC) publishedIPv4(ctx context.Context) (net.IP, error) {
+	var published net.IP
+	for _, domain := range c.Config.DomainNames {
+		addrs, err := c.resolver.LookupIP(ctx, "ip4", dnsName(domain))
+		if err != nil {
+			if isNotFound(err) {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("unable to get a record, %v", err)
+		}
+
+		if len(addrs) == 0 || (published != nil && !published.Equal(addrs[0])) {
+			return nil, nil
+		}
+		published = addrs[0]
+	}
+
+	return published, nil
+}
+
+// Keepalive function to touch every configured domain so that duckdns does
+// not expire it, by re-sending the A and AAAA addresses it publishes. No
+// record changes: the addresses are read from the duckdns authoritative
+// nameservers so that no stale cached address is sent back, the TXT record is
+// not sent, and a domain without an A record is skipped, as duckdns would
+// fill a missing address from the request source. A domain whose addresses
+// can't be read is not touched. An address changed by another client between
+// the lookup and the update is reverted.
+func (c *ClientC) Keepalive(ctx context.Context) error {
+	servers, err := c.authoritativeServers(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to discover duckdns nameservers: %w", err)
+	}
+
+	var errs MultiError
+	touched := 0
+	for _, domain := range c.Config.DomainNames {
+		params, err := c.keepaliveParams(ctx, servers, domain)
+		if err != nil {
+			errs.add(domain, err)
+			continue
+		}
+		if params == nil {
+			klog.Warningf("%sSkipping keepalive of %v, it publishes no ipv4 address", c.logPrefix, domain)
+			continue
+		}
+
+		if _, _, err := c.Do(ctx, params); err != nil {
+			errs.add(domain, err)
+			continue
+		}
+		touched++
+	}
+
+	if touched == 0 && len(errs.Errors) == 0 {
+		return errors.New("no domain publishes an ipv4 address to keep alive")
+	}
+	return errs.errOrNil()
+}
+
+// keepaliveParams returns the update parameters re-sending the addresses a
+// domain publishes on the first authoritative nameserver answering, nil when
+// it has no A record
+func (c *ClientC) keepaliveParams(ctx context.Context, servers []string, domain string) (neturl.Values, error) {
+	var addrs []net.IP
+	var errs []error
+	for _, server := range servers {
+		var err error
+		addrs, err = c.lookupIPAt(ctx, server, dnsName(domain))
+		if err == nil || isNotFound(err) {
+			errs = nil
+			break
+		}
+		errs = append(errs, fmt.Errorf("%s: %w", server, err))
+	}
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("unable to get ip records, %w", errors.Join(errs...))
+	}
+
+	params := neturl.Values{domainsParam: {domain}}
+	for _, addr := range addrs {
+		if addr.To4() != nil {
+			params.Set(ip4Param, addr.String())
+		} else if !params.Has(ip6Param) {
+			params.Set(ip6Param, addr.String())
+		}
+	}
+	if !params.Has(ip4Param) {
+		return nil, nil
+	}
+	return params, nil
+}
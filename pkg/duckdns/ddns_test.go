@@ -0,0 +1,158 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	neturl "net/url"
+	"reflect"
+	"slices"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+func TestUpdateIPv4FromPublic(t *testing.T) {
+	tests := []struct {
+		name        string
+		published   []string
+		wantChanged bool
+	}{
+		{name: "change", published: []string{"198.51.100.1"}, wantChanged: true},
+		{name: "no change", published: []string{"203.0.113.7"}, wantChanged: false},
+		{name: "no record", published: nil, wantChanged: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zone := newFakeZone(t)
+			if tt.published != nil {
+				zone.setA("example.duckdns.org", tt.published...)
+			}
+			echo := newEchoServer(t, http.StatusOK, "203.0.113.7")
+
+			var log requestLog
+			c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")),
+				WithResolver(zone.resolver()), WithIPEchoEndpoints([]string{echo}))
+
+			changed, err := c.UpdateIPv4FromPublic(context.Background())
+			if err != nil {
+				t.Fatalf("UpdateIPv4FromPublic() error = %v", err)
+			}
+			if changed != tt.wantChanged {
+				t.Errorf("UpdateIPv4FromPublic() = %v, want %v", changed, tt.wantChanged)
+			}
+
+			if !tt.wantChanged {
+				if got := log.count(); got != 0 {
+					t.Errorf("requests = %d, want 0", got)
+				}
+				return
+			}
+			if got := log.last(t).URL.Query().Get("ip"); got != "203.0.113.7" {
+				t.Errorf("ip = %q, want 203.0.113.7", got)
+			}
+		})
+	}
+}
+
+func TestUpdateIPv4FromPublicDetectionFailure(t *testing.T) {
+	zone := newFakeZone(t)
+	echo := newEchoServer(t, http.StatusInternalServerError, "")
+
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")),
+		WithResolver(zone.resolver()), WithIPEchoEndpoints([]string{echo}))
+
+	changed, err := c.UpdateIPv4FromPublic(context.Background())
+	if err == nil || changed {
+		t.Fatalf("UpdateIPv4FromPublic() = %v, %v, want false and an error", changed, err)
+	}
+	if got := log.count(); got != 0 {
+		t.Errorf("requests = %d, want 0", got)
+	}
+}
+
+func TestUpdateIPv4FromPublicCanceled(t *testing.T) {
+	echo := newEchoServer(t, http.StatusOK, "203.0.113.7")
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithIPEchoEndpoints([]string{echo}))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	if _, err := c.UpdateIPv4FromPublic(ctx); err == nil {
+		t.Error("UpdateIPv4FromPublic() error = nil with a canceled context")
+	}
+}
+
+func TestKeepalive(t *testing.T) {
+	authoritative := newFakeZone(t)
+	authoritative.setA("both.duckdns.org", "203.0.113.7")
+	authoritative.setAAAA("both.duckdns.org", "2001:db8::1")
+	authoritative.setTXT("both.duckdns.org", "challenge")
+	authoritative.setA("ipv4.duckdns.org", "203.0.113.8")
+	authoritative.setTXT("noip.duckdns.org", "challenge")
+	// a recursive resolver still caching stale addresses
+	stale := newFakeZone(t)
+	stale.setA("both.duckdns.org", "198.51.100.1")
+	stale.setA("ipv4.duckdns.org", "198.51.100.1")
+
+	var log requestLog
+	c := newTestClient(t, newTestConfig("both", "ipv4", "noip"), log.wrap(respond(http.StatusOK, "OK")),
+		WithResolver(stale.resolver()), WithAuthoritativeServers([]string{authoritative.addr}))
+
+	if err := c.Keepalive(context.Background()); err != nil {
+		t.Fatalf("Keepalive() error = %v", err)
+	}
+
+	want := []neturl.Values{
+		{"domains": {"both"}, "token": {testToken}, "ip": {"203.0.113.7"}, "ipv6": {"2001:db8::1"}},
+		{"domains": {"ipv4"}, "token": {testToken}, "ip": {"203.0.113.8"}},
+	}
+	if got := log.count(); got != len(want) {
+		t.Fatalf("requests = %d, want %d", got, len(want))
+	}
+	for i, req := range log.requests {
+		if got := req.URL.Query(); !reflect.DeepEqual(got, want[i]) {
+			t.Errorf("request %d parameters = %v, want %v", i, got, want[i])
+		}
+	}
+	if got := stale.count(dns.TypeA, "both.duckdns.org"); got != 0 {
+		t.Errorf("A lookups on the recursive resolver = %d, want 0", got)
+	}
+}
+
+func TestKeepaliveNothingToTouch(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("example.duckdns.org", "challenge")
+
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")),
+		WithAuthoritativeServers([]string{zone.addr}))
+
+	if err := c.Keepalive(context.Background()); err == nil {
+		t.Fatal("Keepalive() error = nil without any ipv4 address, want an error")
+	}
+	if got := log.count(); got != 0 {
+		t.Errorf("requests = %d, want 0", got)
+	}
+}
+
+func TestKeepaliveLookupFailure(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setA("good.duckdns.org", "203.0.113.7")
+	zone.setRcode("broken.duckdns.org", dns.RcodeRefused)
+
+	var log requestLog
+	c := newTestClient(t, newTestConfig("good", "broken"), log.wrap(respond(http.StatusOK, "OK")),
+		WithAuthoritativeServers([]string{zone.addr}))
+
+	err := c.Keepalive(context.Background())
+	var multi *MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 1 || multi.Errors[0].Domain != "broken" {
+		t.Fatalf("Keepalive() error = %v, want a failure of broken only", err)
+	}
+	// a domain whose addresses can't be read is not touched
+	if got := log.domains(); !slices.Equal(got, []string{"good"}) {
+		t.Errorf("touched domains = %v, want [good]", got)
+	}
+}
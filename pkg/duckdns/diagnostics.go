@@ -0,0 +1,133 @@
+package duckdns
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	neturl "net/url"
+	"sort"
+	"strings"
+	"time"
+)
+
+const redacted = "[REDACTED]"
+
+// ConfigSnapshot function to return the effective client configuration for
+// diagnostics, the token is always redacted
+func (c *ClientC) ConfigSnapshot() map[string]any {
+	return map[string]any{
+		"baseURL":   c.BaseURL,
+		"userAgent": c.UserAgent,
+		"timeout":   c.httpClient.Timeout.String(),
+		"domains":   append([]string(nil), c.Config.DomainNames...),
+		"token":     redacted,
+		"verbose":   c.verbose(context.Background()),
+		"retry": map[string]any{
+			"maxAttempts":          c.Retry.MaxAttempts,
+			"baseDelay":            c.Retry.BaseDelay.String(),
+			"maxDelay":             c.Retry.MaxDelay.String(),
+			"minAttemptTime":       c.Retry.MinAttemptTime.String(),
+			"retryOnEmptyResponse": c.Retry.RetryOnEmptyResponse,
+			"timeoutRetries":       c.Retry.TimeoutRetries,
+			"serverErrorRetries":   c.Retry.ServerErrorRetries,
+			"clientErrorRetries":   c.Retry.ClientErrorRetries,
+		},
+		"managedTransport": c.transport != nil,
+	}
+}
+
+// Settings structure containing the numeric tunables in effect for a client
+type Settings struct {
+	Timeout       time.Duration
+	RetryAttempts int
+
+	// TimeoutAttempts, ServerErrorAttempts and ClientErrorAttempts are the
+	// attempts allowed for a network timeout, a 5xx and a 4xx status
+	TimeoutAttempts     int
+	ServerErrorAttempts int
+	ClientErrorAttempts int
+
+	BackoffBase    time.Duration
+	BackoffMax     time.Duration
+	MinAttemptTime time.Duration
+
+	// RateLimit is the least interval between two requests, zero when they
+	// are not limited. RateLimitConfigured reports whether it was set with
+	// WithRateLimit rather than defaulted.
+	RateLimit           time.Duration
+	RateLimitConfigured bool
+}
+
+// EffectiveSettings function to return the resolved tunables of the client,
+// e.g. to label metrics or log them at startup
+func (c *ClientC) EffectiveSettings() Settings {
+	timeout, serverError, clientError := c.Retry.classAttempts()
+	var rateLimit time.Duration
+	if c.limiter != nil {
+		rateLimit = c.limiter.interval
+	}
+	return Settings{
+		Timeout:       c.httpClient.Timeout,
+		RetryAttempts: max(c.Retry.MaxAttempts, 1),
+		BackoffBase:   c.Retry.BaseDelay,
+
+		TimeoutAttempts:     timeout,
+		ServerErrorAttempts: serverError,
+		ClientErrorAttempts: clientError,
+		BackoffMax:          c.Retry.MaxDelay,
+		MinAttemptTime:      c.Retry.MinAttemptTime,
+		RateLimit:           rateLimit,
+		RateLimitConfigured: c.limiter != nil,
+	}
+}
+
+// CurlString function to return a curl command reproducing the update request
+// built for params, with the http method configured for its operation. The
+// token is masked and must be filled in before running.
+func (c *ClientC) CurlString(params neturl.Values) string {
+	var b strings.Builder
+	fmt.Fprintf(&b, "# token redacted, replace %s with the duckdns token\n", curlTokenPlaceholder)
+	b.WriteString("curl -sS -A " + shellQuote(c.userAgent(context.Background())))
+
+	headers := c.headerSnapshot()
+	keys := make([]string, 0, len(headers))
+	for key := range headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		for _, value := range headers[key] {
+			if sensitiveHeaders[key] {
+				value = redacted
+			}
+			b.WriteString(" -H " + shellQuote(key+": "+value))
+		}
+	}
+
+	url := strings.Replace(c.ObfuscatedURL(params),
+		tokenParam+"="+obfuscatedToken, tokenParam+"="+curlTokenPlaceholder, 1)
+	if c.Config.method(params) == http.MethodPost {
+		url, query, _ := strings.Cut(url, "?")
+		b.WriteString(" -X POST -H " + shellQuote("Content-Type: application/x-www-form-urlencoded"))
+		b.WriteString(" --data " + shellQuote(query) + " " + shellQuote(url))
+		return b.String()
+	}
+
+	b.WriteString(" " + shellQuote(url))
+	return b.String()
+}
+
+// curlTokenPlaceholder stands for the token in the CurlString command
+const curlTokenPlaceholder = "$DUCKDNS_TOKEN"
+
+// sensitiveHeaders are the headers whose value CurlString redacts
+var sensitiveHeaders = map[string]bool{
+	"Authorization":       true,
+	"Proxy-Authorization": true,
+	"Cookie":              true,
+}
+
+// shellQuote quotes s as a single POSIX shell word
+func shellQuote(s string) string {
+	return "'" + strings.ReplaceAll(s, "'", `'\''`) + "'"
+}
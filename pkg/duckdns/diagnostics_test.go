@@ -0,0 +1,128 @@
+package duckdns
+
+import (
+	"encoding/json"
+	"net/http"
+	neturl "net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestConfigSnapshotRedactsToken(t *testing.T) {
+	c := newTestClient(t, newTestConfig("first", "second"), respond(http.StatusOK, "OK"))
+
+	data, err := json.Marshal(c.ConfigSnapshot())
+	if err != nil {
+		t.Fatalf("json.Marshal() error = %v", err)
+	}
+	if strings.Contains(string(data), testToken) {
+		t.Fatalf("snapshot %s holds the token", data)
+	}
+
+	var snapshot map[string]any
+	if err := json.Unmarshal(data, &snapshot); err != nil {
+		t.Fatalf("json.Unmarshal() error = %v", err)
+	}
+	if snapshot["token"] != redacted {
+		t.Errorf("token = %v, want %v", snapshot["token"], redacted)
+	}
+	if snapshot["baseURL"] != c.BaseURL {
+		t.Errorf("baseURL = %v, want %v", snapshot["baseURL"], c.BaseURL)
+	}
+	if domains, _ := snapshot["domains"].([]any); len(domains) != 2 {
+		t.Errorf("domains = %v, want both configured domains", snapshot["domains"])
+	}
+}
+
+func TestEffectiveSettingsDefaults(t *testing.T) {
+	c := NewClient(nil, newTestConfig())
+	defer c.Close()
+
+	want := Settings{
+		RetryAttempts:       3,
+		TimeoutAttempts:     6,
+		ServerErrorAttempts: 3,
+		ClientErrorAttempts: 1,
+		BackoffBase:         time.Second,
+		BackoffMax:          10 * time.Second,
+		MinAttemptTime:      2 * time.Second,
+		RateLimit:           0,
+		RateLimitConfigured: false,
+	}
+	if got := c.EffectiveSettings(); got != want {
+		t.Errorf("EffectiveSettings() = %+v, want %+v", got, want)
+	}
+}
+
+func TestEffectiveSettingsRateLimit(t *testing.T) {
+	c := NewClient(nil, newTestConfig(), WithRateLimit(time.Minute))
+	defer c.Close()
+
+	got := c.EffectiveSettings()
+	if got.RateLimit != time.Minute || !got.RateLimitConfigured {
+		t.Errorf("rate limit = %v configured %v, want 1m configured", got.RateLimit, got.RateLimitConfigured)
+	}
+}
+
+func TestEffectiveSettingsCustomRetryOn(t *testing.T) {
+	c := NewClient(&http.Client{Timeout: 5 * time.Second}, newTestConfig())
+	c.Retry.MaxAttempts = 4
+	c.Retry.RetryOn = DefaultRetryOn
+
+	got := c.EffectiveSettings()
+	if got.Timeout != 5*time.Second {
+		t.Errorf("Timeout = %v, want 5s", got.Timeout)
+	}
+	// class budgets only apply to the default policy
+	if got.TimeoutAttempts != 4 || got.ServerErrorAttempts != 4 || got.ClientErrorAttempts != 4 {
+		t.Errorf("class attempts = %d/%d/%d, want 4/4/4",
+			got.TimeoutAttempts, got.ServerErrorAttempts, got.ClientErrorAttempts)
+	}
+}
+
+func TestCurlStringGet(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+
+	got := c.CurlString(neturl.Values{"txt": {"value"}})
+	want := "# token redacted, replace $DUCKDNS_TOKEN with the duckdns token\n" +
+		"curl -sS -A 'duckdns-go/1.0.3' '" + c.BaseURL + "/update?domains=example&token=$DUCKDNS_TOKEN&txt=value'"
+	if got != want {
+		t.Errorf("CurlString() =\n%s\nwant\n%s", got, want)
+	}
+}
+
+func TestCurlStringPost(t *testing.T) {
+	config := newTestConfig()
+	config.Methods = map[Operation]string{OperationTXT: http.MethodPost}
+	c := newTestClient(t, config, respond(http.StatusOK, "OK"))
+
+	got := c.CurlString(neturl.Values{"txt": {"value"}})
+	want := "# token redacted, replace $DUCKDNS_TOKEN with the duckdns token\n" +
+		"curl -sS -A 'duckdns-go/1.0.3' -X POST -H 'Content-Type: application/x-www-form-urlencoded'" +
+		" --data 'domains=example&token=$DUCKDNS_TOKEN&txt=value' '" + c.BaseURL + "/update'"
+	if got != want {
+		t.Errorf("CurlString() =\n%s\nwant\n%s", got, want)
+	}
+
+	// the other operations keep the default GET
+	if got := c.CurlString(neturl.Values{"ip": {""}}); strings.Contains(got, "-X POST") {
+		t.Errorf("CurlString() = %s, want a GET for the ip operation", got)
+	}
+}
+
+func TestCurlStringHeaders(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+	c.SetHeader("X-Tenant", "it's mine")
+	c.SetHeader("Authorization", "Bearer secret")
+
+	got := c.CurlString(neturl.Values{"txt": {"value"}})
+	for _, want := range []string{`-H 'Authorization: [REDACTED]'`, `-H 'X-Tenant: it'\''s mine'`} {
+		if !strings.Contains(got, want) {
+			t.Errorf("CurlString() = %s, want it to hold %s", got, want)
+		}
+	}
+	if strings.Contains(got, "secret") || strings.Contains(got, testToken) {
+		t.Errorf("CurlString() = %s holds a secret", got)
+	}
+}
@@ -0,0 +1,397 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// WithResolver option to set the resolver used for A, AAAA and TXT lookups
+func WithResolver(resolver *net.Resolver) Option {
+	return func(c *ClientC) error {
+		if resolver == nil {
+			return errors.New("resolver must be non-nil")
+		}
+		c.resolver = resolver
+		c.resolverUncached = false
+		return nil
+	}
+}
+
+// WithUncachedResolver option to set a resolver that answers without a cache,
+// e.g. one querying authoritative nameservers directly
+func WithUncachedResolver(resolver *net.Resolver) Option {
+	return func(c *ClientC) error {
+		if resolver == nil {
+			return errors.New("resolver must be non-nil")
+		}
+		c.resolver = resolver
+		c.resolverUncached = true
+		return nil
+	}
+}
+
+// PrimeResolver function to issue a throwaway TXT lookup of the first domain
+// so that later propagation checks hit a warm resolver cache, it does nothing
+// when the resolver has no cache
+func (c *ClientC) PrimeResolver(ctx context.Context) {
+	if c.resolverUncached {
+		return
+	}
+
+	if _, err := c.lookupTXT(ctx, dnsName(c.Config.DomainNames[0])); err != nil && !isNotFound(err) {
+		klog.V(4).Infof("%sPriming resolver failed: %v", c.logPrefix, err)
+	}
+}
+
+// Resolver function to return the resolver used for lookups, the same
+// instance is reused by every lookup of the client
+func (c *ClientC) Resolver() *net.Resolver {
+	return c.resolver
+}
+
+// serverResolver returns the resolver dedicated to a nameserver, created once
+// per nameserver and then reused
+func (c *ClientC) serverResolver(server string) *net.Resolver {
+	c.serverResolversMu.Lock()
+	defer c.serverResolversMu.Unlock()
+
+	if c.serverResolvers == nil {
+		c.serverResolvers = make(map[string]*net.Resolver)
+	}
+	r, ok := c.serverResolvers[server]
+	if !ok {
+		r = newAddrResolver(server, c.resolverDial)
+		c.serverResolvers[server] = r
+	}
+	return r
+}
+
+// isNotFound reports whether a lookup error is a definitive NXDOMAIN/no data
+func isNotFound(err error) bool {
+	var dnsErr *net.DNSError
+	return errors.As(err, &dnsErr) && dnsErr.IsNotFound
+}
+
+// DomainsExist function to report which configured domains currently resolve
+// to an A, AAAA or TXT record
+func (c *ClientC) DomainsExist(ctx context.Context) (map[string]bool, error) {
+	out := make(map[string]bool, len(c.Config.DomainNames))
+	var mu sync.Mutex
+
+	err := forEachDomain(ctx, c.Config.DomainNames, func(ctx context.Context, domain string) error {
+		exists, err := c.domainExists(ctx, domain)
+
+		mu.Lock()
+		defer mu.Unlock()
+		out[domain] = exists
+		return err
+	})
+	return out, err
+}
+
+func (c *ClientC) domainExists(ctx context.Context, domain string) (bool, error) {
+	name := dnsName(domain)
+
+	addrs, err := c.resolver.LookupIP(ctx, "ip", name)
+	if err != nil && !isNotFound(err) {
+		return false, err
+	}
+	if len(addrs) > 0 {
+		return true, nil
+	}
+
+	txt, err := c.lookupTXT(ctx, name)
+	if err != nil && !isNotFound(err) {
+		return false, err
+	}
+	return len(txt) > 0, nil
+}
+
+// hedging holds the resolvers raced against the primary one for TXT lookups
+type hedging struct {
+	delay     time.Duration
+	resolvers []*net.Resolver
+}
+
+// newAddrResolver returns a resolver sending every query to the given
+// nameserver, a missing port defaults to 53
+func newAddrResolver(addr string, dial dialFunc) *net.Resolver {
+	if _, _, err := net.SplitHostPort(addr); err != nil {
+		addr = net.JoinHostPort(addr, "53")
+	}
+	if dial == nil {
+		var d net.Dialer
+		dial = d.DialContext
+	}
+
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			return dial(ctx, network, addr)
+		},
+	}
+}
+
+// dialFunc dials the connections of a resolver
+type dialFunc func(ctx context.Context, network, address string) (net.Conn, error)
+
+// WithResolverDialer option to dial the connections of the resolver lookups
+// with dial, e.g. to leave through a dedicated egress address. It replaces
+// the resolver and applies to the nameserver resolvers of the authoritative
+// lookups and of WithHedging when given before it, and to the iterative
+// resolution of VerifyChallengeVisible, but not to LookupTXTDetailed.
+func WithResolverDialer(dial func(ctx context.Context, network, address string) (net.Conn, error)) Option {
+	return func(c *ClientC) error {
+		if dial == nil {
+			return errors.New("resolver dialer must be non-nil")
+		}
+		c.resolverDial = dial
+		c.resolver = &net.Resolver{PreferGo: true, Dial: dial}
+		c.resolverUncached = false
+		return nil
+	}
+}
+
+// WithHedging option to fire a TXT lookup against the next of the given
+// nameservers whenever the previous lookup has not answered within delay, the
+// first answer wins and the other lookups are canceled
+func WithHedging(delay time.Duration, resolvers []string) Option {
+	return func(c *ClientC) error {
+		if delay <= 0 {
+			return errors.New("hedging delay must be positive")
+		}
+		if len(resolvers) == 0 {
+			return errors.New("hedging requires at least one resolver")
+		}
+
+		h := &hedging{delay: delay}
+		for _, addr := range resolvers {
+			h.resolvers = append(h.resolvers, newAddrResolver(addr, c.resolverDial))
+		}
+		c.hedging = h
+		return nil
+	}
+}
+
+// WithMaxDNSConcurrency option to bound the number of TXT lookups of the
+// client in flight at once, e.g. across many propagation waits. Unlimited by
+// default.
+func WithMaxDNSConcurrency(n int) Option {
+	return func(c *ClientC) error {
+		if n <= 0 {
+			return errors.New("max dns concurrency must be positive")
+		}
+		c.dnsSem = make(chan struct{}, n)
+		return nil
+	}
+}
+
+// lookupTXT looks up the normalized TXT records of name
+func (c *ClientC) lookupTXT(ctx context.Context, name string) ([]string, error) {
+	release, err := c.acquireDNS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	txt, err := c.resolveTXT(ctx, name)
+	for i := range txt {
+		txt[i] = normalizeTXT(txt[i])
+	}
+	return txt, err
+}
+
+// lookupTXTAt looks up the normalized TXT records of name on a nameserver,
+// within the dns concurrency cap of the client
+func (c *ClientC) lookupTXTAt(ctx context.Context, server, name string) ([]string, error) {
+	release, err := c.acquireDNS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	txt, err := c.serverResolver(server).LookupTXT(ctx, name)
+	for i := range txt {
+		txt[i] = normalizeTXT(txt[i])
+	}
+	return txt, err
+}
+
+// lookupIPAt looks up the A and AAAA records of name on a nameserver, within
+// the dns concurrency cap of the client
+func (c *ClientC) lookupIPAt(ctx context.Context, server, name string) ([]net.IP, error) {
+	release, err := c.acquireDNS(ctx)
+	if err != nil {
+		return nil, err
+	}
+	defer release()
+
+	return c.serverResolver(server).LookupIP(ctx, "ip", name)
+}
+
+// acquireDNS takes a slot of the dns concurrency cap, if any, and returns
+// the function releasing it
+func (c *ClientC) acquireDNS(ctx context.Context) (func(), error) {
+	if c.dnsSem == nil {
+		return func() {}, nil
+	}
+
+	select {
+	case c.dnsSem <- struct{}{}:
+		return func() { <-c.dnsSem }, nil
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+}
+
+// normalizeTXT joins a TXT value split into quoted character-strings, e.g.
+// "abc" "def", and strips the quotes around a single quoted value
+func normalizeTXT(record string) string {
+	s := strings.TrimSpace(record)
+	if !strings.HasPrefix(s, `"`) {
+		return s
+	}
+
+	var b strings.Builder
+	for strings.HasPrefix(s, `"`) {
+		end := strings.Index(s[1:], `"`)
+		if end < 0 {
+			return strings.Trim(strings.TrimSpace(record), `"`)
+		}
+		b.WriteString(s[1 : end+1])
+		s = strings.TrimSpace(s[end+2:])
+	}
+
+	if s != "" {
+		return strings.Trim(strings.TrimSpace(record), `"`)
+	}
+	return b.String()
+}
+
+// resolveTXT looks up the TXT records of name, hedged when configured
+func (c *ClientC) resolveTXT(ctx context.Context, name string) ([]string, error) {
+	if c.hedging == nil {
+		return c.resolver.LookupTXT(ctx, name)
+	}
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	type result struct {
+		txt []string
+		err error
+	}
+
+	resolvers := append([]*net.Resolver{c.resolver}, c.hedging.resolvers...)
+	results := make(chan result, len(resolvers))
+	next, inflight := 0, 0
+	launch := func() {
+		r := resolvers[next]
+		next++
+		inflight++
+		go func() {
+			txt, err := r.LookupTXT(ctx, name)
+			results <- result{txt: txt, err: err}
+		}()
+	}
+
+	timer := time.NewTimer(c.hedging.delay)
+	defer timer.Stop()
+
+	launch()
+	var firstErr error
+	for inflight > 0 {
+		select {
+		case r := <-results:
+			inflight--
+			if r.err == nil || isNotFound(r.err) {
+				return r.txt, r.err
+			}
+			if firstErr == nil {
+				firstErr = r.err
+			}
+			if next < len(resolvers) {
+				launch()
+			}
+		case <-timer.C:
+			if next < len(resolvers) {
+				launch()
+				timer.Reset(c.hedging.delay)
+			}
+		}
+	}
+
+	return nil, firstErr
+}
+
+// WithAuthoritativeServers option to query the given nameservers, as host or
+// host:port, instead of discovering the duckdns.org nameservers, e.g. when the
+// discovery is blocked by a split-horizon resolver
+func WithAuthoritativeServers(servers []string) Option {
+	return func(c *ClientC) error {
+		if len(servers) == 0 {
+			return errors.New("at least one authoritative server is required")
+		}
+		c.authServers = slices.Clone(servers)
+		return nil
+	}
+}
+
+// authoritativeServers returns the nameservers of the duckdns.org zone, or
+// the ones set with WithAuthoritativeServers
+func (c *ClientC) authoritativeServers(ctx context.Context) ([]string, error) {
+	if len(c.authServers) > 0 {
+		return c.authServers, nil
+	}
+
+	ns, err := c.resolver.LookupNS(ctx, "duckdns.org")
+	if err != nil {
+		return nil, err
+	}
+	if len(ns) == 0 {
+		return nil, errors.New("no nameservers found for duckdns.org")
+	}
+
+	servers := make([]string, 0, len(ns))
+	for _, n := range ns {
+		servers = append(servers, strings.TrimSuffix(n.Host, "."))
+	}
+	return servers, nil
+}
+
+// GetRecordsAuthoritative function to get all TXT records from the duckdns
+// authoritative nameservers, bypassing resolver caches. When the nameservers
+// can't be discovered it falls back to GetRecords, warning once per client.
+func (c *ClientC) GetRecordsAuthoritative(ctx context.Context) ([]string, error) {
+	servers, err := c.authoritativeServers(ctx)
+	if err != nil {
+		c.authFallbackOnce.Do(func() {
+			klog.Warningf("%sUnable to discover duckdns nameservers, falling back to the recursive resolver: %v", c.logPrefix, err)
+		})
+		return c.GetRecords(ctx)
+	}
+
+	name := dnsName(c.Config.DomainNames[0])
+	var errs []error
+	for _, server := range servers {
+		txt, err := c.lookupTXTAt(ctx, server, name)
+		if err != nil && !isNotFound(err) {
+			errs = append(errs, fmt.Errorf("%s: %w", server, err))
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("unable to get txt record, %v", err)
+		}
+		return txt, nil
+	}
+
+	return nil, fmt.Errorf("unable to get txt record, %w", errors.Join(errs...))
+}
@@ -0,0 +1,371 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestDomainsExist(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setA("withip.duckdns.org", "203.0.113.7")
+	zone.setTXT("withtxt.duckdns.org", "value")
+
+	c := newTestClient(t, newTestConfig("withip", "withtxt", "missing"), respond(http.StatusOK, "OK"), WithResolver(zone.resolver()))
+
+	got, err := c.DomainsExist(context.Background())
+	if err != nil {
+		t.Fatalf("DomainsExist() error = %v", err)
+	}
+	want := map[string]bool{"withip": true, "withtxt": true, "missing": false}
+	for domain, exists := range want {
+		if got[domain] != exists {
+			t.Errorf("DomainsExist()[%q] = %v, want %v", domain, got[domain], exists)
+		}
+	}
+}
+
+func TestDomainsExistLookupFailure(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setA("withip.duckdns.org", "203.0.113.7")
+	zone.setRcode("broken.duckdns.org", dns.RcodeRefused)
+
+	c := newTestClient(t, newTestConfig("withip", "broken"), respond(http.StatusOK, "OK"), WithResolver(zone.resolver()))
+
+	got, err := c.DomainsExist(context.Background())
+	var multi *MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 1 || multi.Errors[0].Domain != "broken" {
+		t.Fatalf("DomainsExist() error = %v, want a failure of broken only", err)
+	}
+	if !got["withip"] {
+		t.Errorf("DomainsExist()[withip] = false, want true")
+	}
+}
+
+func TestHedgingUsesFastestResolver(t *testing.T) {
+	slow := newFakeZone(t)
+	slow.setTXT("example.duckdns.org", "slow")
+	slow.setDelay(time.Second)
+	fast := newFakeZone(t)
+	fast.setTXT("example.duckdns.org", "fast")
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"),
+		WithResolver(slow.resolver()), WithHedging(20*time.Millisecond, []string{fast.addr}))
+
+	start := time.Now()
+	txt, err := c.GetRecord()
+	if err != nil {
+		t.Fatalf("GetRecord() error = %v", err)
+	}
+	if txt != "fast" {
+		t.Errorf("GetRecord() = %q, want fast", txt)
+	}
+	if elapsed := time.Since(start); elapsed >= time.Second {
+		t.Errorf("GetRecord() took %v, want the hedged answer before the slow one", elapsed)
+	}
+	if got := fast.count(dns.TypeTXT, "example.duckdns.org"); got != 1 {
+		t.Errorf("hedged lookups = %d, want 1", got)
+	}
+}
+
+func TestHedgingNotFiredWhenPrimaryAnswers(t *testing.T) {
+	primary := newFakeZone(t)
+	primary.setTXT("example.duckdns.org", "primary")
+	hedge := newFakeZone(t)
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"),
+		WithResolver(primary.resolver()), WithHedging(time.Second, []string{hedge.addr}))
+
+	txt, err := c.GetRecord()
+	if err != nil || txt != "primary" {
+		t.Fatalf("GetRecord() = %q, %v, want primary", txt, err)
+	}
+	if got := hedge.count(dns.TypeTXT, "example.duckdns.org"); got != 0 {
+		t.Errorf("hedged lookups = %d, want 0", got)
+	}
+}
+
+func TestNormalizeTXT(t *testing.T) {
+	tests := []struct {
+		record string
+		want   string
+	}{
+		{record: "plain", want: "plain"},
+		{record: `"quoted"`, want: "quoted"},
+		{record: `"abc" "def"`, want: "abcdef"},
+		{record: ` "abc"  "def" `, want: "abcdef"},
+		{record: `"abc""def"`, want: "abcdef"},
+		{record: `"unterminated`, want: "unterminated"},
+		{record: `"abc" trailing`, want: `abc" trailing`},
+		{record: "", want: ""},
+	}
+
+	for _, tt := range tests {
+		if got := normalizeTXT(tt.record); got != tt.want {
+			t.Errorf("normalizeTXT(%q) = %q, want %q", tt.record, got, tt.want)
+		}
+	}
+}
+
+func TestGetRecordsSplitAndQuoted(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(z *fakeZone)
+		want string
+	}{
+		{
+			name: "split",
+			set:  func(z *fakeZone) { z.setTXTStrings("example.duckdns.org", "abc", "def") },
+			want: "abcdef",
+		},
+		{
+			name: "quoted",
+			set:  func(z *fakeZone) { z.setTXT("example.duckdns.org", `"abcdef"`) },
+			want: "abcdef",
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zone := newFakeZone(t)
+			tt.set(zone)
+			c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithResolver(zone.resolver()))
+
+			txt, err := c.GetRecords(context.Background())
+			if err != nil {
+				t.Fatalf("GetRecords() error = %v", err)
+			}
+			if len(txt) != 1 || txt[0] != tt.want {
+				t.Errorf("GetRecords() = %q, want [%q]", txt, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetRecordsAuthoritativeFallback(t *testing.T) {
+	logs := captureLogs(t, 0)
+
+	zone := newFakeZone(t)
+	zone.setRcode("duckdns.org", dns.RcodeRefused)
+	zone.setTXT("example.duckdns.org", "value")
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithResolver(zone.resolver()))
+
+	for i := 0; i < 2; i++ {
+		txt, err := c.GetRecordsAuthoritative(context.Background())
+		if err != nil {
+			t.Fatalf("GetRecordsAuthoritative() error = %v", err)
+		}
+		if len(txt) != 1 || txt[0] != "value" {
+			t.Errorf("GetRecordsAuthoritative() = %q, want [value]", txt)
+		}
+	}
+
+	if got := zone.count(dns.TypeTXT, "example.duckdns.org"); got != 2 {
+		t.Errorf("recursive lookups = %d, want 2", got)
+	}
+	if got := logs.lines("falling back to the recursive resolver"); len(got) != 1 || !strings.HasPrefix(got[0], "W") {
+		t.Errorf("fallback warnings = %q, want a single warning", got)
+	}
+}
+
+func TestWithResolverCustomDial(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("example.duckdns.org", "value")
+
+	var mu sync.Mutex
+	var dials int
+	resolver := &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, _ string) (net.Conn, error) {
+			mu.Lock()
+			dials++
+			mu.Unlock()
+			var d net.Dialer
+			return d.DialContext(ctx, network, zone.addr)
+		},
+	}
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithResolver(resolver))
+	if c.Resolver() != resolver {
+		t.Fatal("Resolver() is not the injected resolver")
+	}
+
+	for i := 0; i < 2; i++ {
+		txt, err := c.GetRecords(context.Background())
+		if err != nil || len(txt) != 1 || txt[0] != "value" {
+			t.Fatalf("GetRecords() = %q, %v, want [value]", txt, err)
+		}
+	}
+	mu.Lock()
+	defer mu.Unlock()
+	if dials == 0 {
+		t.Error("the injected resolver dialer was not used")
+	}
+	if got := zone.count(dns.TypeTXT, "example.duckdns.org"); got != 2 {
+		t.Errorf("TXT lookups = %d, want 2", got)
+	}
+}
+
+// recordingDialer returns a dialer recording the addresses it is asked for
+// while connecting to addr
+func recordingDialer(addr string, mu *sync.Mutex, dialed *[]string) func(ctx context.Context, network, address string) (net.Conn, error) {
+	return func(ctx context.Context, network, address string) (net.Conn, error) {
+		mu.Lock()
+		*dialed = append(*dialed, address)
+		mu.Unlock()
+		var d net.Dialer
+		return d.DialContext(ctx, network, addr)
+	}
+}
+
+func TestWithResolverDialer(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("example.duckdns.org", "value")
+
+	var mu sync.Mutex
+	var dialed []string
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"),
+		WithResolverDialer(recordingDialer(zone.addr, &mu, &dialed)))
+
+	txt, err := c.GetRecords(context.Background())
+	if err != nil || len(txt) != 1 || txt[0] != "value" {
+		t.Fatalf("GetRecords() = %q, %v, want [value]", txt, err)
+	}
+	if got := zone.count(dns.TypeTXT, "example.duckdns.org"); got != 1 {
+		t.Errorf("TXT lookups = %d, want 1", got)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if len(dialed) == 0 {
+		t.Fatal("the resolver dialer was not invoked")
+	}
+	for _, address := range dialed {
+		if _, port, err := net.SplitHostPort(address); err != nil || port != "53" {
+			t.Errorf("dialed %q, want a nameserver address", address)
+		}
+	}
+}
+
+func TestWithResolverDialerHedging(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("example.duckdns.org", "value")
+	zone.setDelay(50 * time.Millisecond)
+
+	// the hedged nameserver is unreachable but for the dialer
+	var mu sync.Mutex
+	var dialed []string
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"),
+		WithResolverDialer(recordingDialer(zone.addr, &mu, &dialed)),
+		WithHedging(10*time.Millisecond, []string{"192.0.2.1"}))
+
+	if _, err := c.GetRecords(context.Background()); err != nil {
+		t.Fatalf("GetRecords() error = %v", err)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	if !slices.Contains(dialed, "192.0.2.1:53") {
+		t.Errorf("dialed %v, want the hedged nameserver among them", dialed)
+	}
+}
+
+func TestWithResolverDialerNil(t *testing.T) {
+	if err := WithResolverDialer(nil)(&ClientC{}); err == nil {
+		t.Error("WithResolverDialer(nil) error = nil, want an error")
+	}
+}
+
+func TestPrimeResolver(t *testing.T) {
+	zone := newFakeZone(t)
+	c := newTestClient(t, newTestConfig("first", "second"), respond(http.StatusOK, "OK"), WithResolver(zone.resolver()))
+
+	c.PrimeResolver(context.Background())
+	if got := zone.count(dns.TypeTXT, "first.duckdns.org"); got != 1 {
+		t.Errorf("TXT lookups of first = %d, want 1", got)
+	}
+	if got := zone.count(dns.TypeTXT, "second.duckdns.org"); got != 0 {
+		t.Errorf("TXT lookups of second = %d, want 0", got)
+	}
+}
+
+func TestPrimeResolverUncached(t *testing.T) {
+	zone := newFakeZone(t)
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithUncachedResolver(zone.resolver()))
+
+	c.PrimeResolver(context.Background())
+	if got := zone.count(dns.TypeTXT, "example.duckdns.org"); got != 0 {
+		t.Errorf("TXT lookups = %d, want 0", got)
+	}
+}
+
+func TestWithMaxDNSConcurrency(t *testing.T) {
+	tests := []struct {
+		name    string
+		opts    []Option
+		maxPeak int
+	}{
+		{name: "capped", opts: []Option{WithMaxDNSConcurrency(2)}, maxPeak: 2},
+		{name: "unlimited", maxPeak: 8},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zone := newFakeZone(t)
+			zone.setTXT("example.duckdns.org", "value")
+			zone.setDelay(30 * time.Millisecond)
+
+			opts := append([]Option{WithResolver(zone.resolver()), WithInitialPropagationDelay(0)}, tt.opts...)
+			c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), opts...)
+
+			var wg sync.WaitGroup
+			for i := 0; i < 8; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					if err := c.WaitForRecord(context.Background(), "value", 10*time.Millisecond, 5*time.Second); err != nil {
+						t.Errorf("WaitForRecord() error = %v", err)
+					}
+				}()
+			}
+			wg.Wait()
+
+			peak := zone.peakInflight()
+			if peak > tt.maxPeak {
+				t.Errorf("peak in-flight lookups = %d, want at most %d", peak, tt.maxPeak)
+			}
+			if tt.opts == nil && peak <= 2 {
+				t.Errorf("peak in-flight lookups = %d without a cap, want more than 2", peak)
+			}
+		})
+	}
+}
+
+func TestWithMaxDNSConcurrencyCanceled(t *testing.T) {
+	zone := newFakeZone(t)
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"),
+		WithResolver(zone.resolver()), WithMaxDNSConcurrency(1))
+
+	release, err := c.acquireDNS(context.Background())
+	if err != nil {
+		t.Fatalf("acquireDNS() error = %v", err)
+	}
+	defer release()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.GetRecords(ctx); err == nil || !strings.Contains(err.Error(), context.DeadlineExceeded.Error()) {
+		t.Fatalf("GetRecords() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := zone.count(dns.TypeTXT, "example.duckdns.org"); got != 0 {
+		t.Errorf("TXT lookups = %d, want 0", got)
+	}
+}
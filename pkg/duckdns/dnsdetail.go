@@ -0,0 +1,101 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+// TXTLookup structure containing the details of a TXT query
+type TXTLookup struct {
+	Records []string
+	TTL     time.Duration
+	Server  string
+	Latency time.Duration
+}
+
+// LookupTXTDetailed function to query the TXT record of the first domain on
+// the duckdns authoritative nameservers and return the records with their
+// TTL, the answering server and the query latency
+func (c *ClientC) LookupTXTDetailed(ctx context.Context) (*TXTLookup, error) {
+	servers, err := c.authoritativeServers(ctx)
+	if err != nil {
+		return nil, fmt.Errorf("unable to discover duckdns nameservers: %w", err)
+	}
+
+	var errs []error
+	for _, server := range servers {
+		lookup, err := c.LookupTXTDetailedAt(ctx, server)
+		if err == nil {
+			return lookup, nil
+		}
+		errs = append(errs, err)
+	}
+	return nil, errors.Join(errs...)
+}
+
+// LookupTXTDetailedAt function to query the TXT record of the first domain on
+// the given nameserver, a truncated udp answer is retried over tcp
+func (c *ClientC) LookupTXTDetailedAt(ctx context.Context, server string) (*TXTLookup, error) {
+	if _, _, err := net.SplitHostPort(server); err != nil {
+		server = net.JoinHostPort(server, "53")
+	}
+
+	msg := new(dns.Msg)
+	msg.SetQuestion(dns.Fqdn(dnsName(c.Config.DomainNames[0])), dns.TypeTXT)
+
+	client := &dns.Client{Net: "udp"}
+	resp, rtt, err := client.ExchangeContext(ctx, msg, server)
+	if err == nil && resp.Truncated {
+		client.Net = "tcp"
+		resp, rtt, err = client.ExchangeContext(ctx, msg, server)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("%s: %w", server, err)
+	}
+	if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+		return nil, fmt.Errorf("%s: %s", server, dns.RcodeToString[resp.Rcode])
+	}
+
+	lookup := &TXTLookup{Server: server, Latency: rtt}
+	for _, rr := range resp.Answer {
+		txt, ok := rr.(*dns.TXT)
+		if !ok {
+			continue
+		}
+		lookup.Records = append(lookup.Records, strings.Join(txt.Txt, ""))
+		if ttl := time.Duration(txt.Hdr.Ttl) * time.Second; len(lookup.Records) == 1 || ttl < lookup.TTL {
+			lookup.TTL = ttl
+		}
+	}
+
+	return lookup, nil
+}
+
+const (
+	// propagationMargin is added to the observed TTL by EstimatePropagation
+	propagationMargin = 30 * time.Second
+
+	// defaultPropagationEstimate is returned when no TTL can be observed
+	defaultPropagationEstimate = 2 * time.Minute
+)
+
+// EstimatePropagation function to recommend a propagation budget from the
+// TTL of the current TXT record plus a margin, a conservative default is
+// returned when the domain has no TXT record or the lookup fails
+func (c *ClientC) EstimatePropagation(ctx context.Context) (time.Duration, error) {
+	lookup, err := c.LookupTXTDetailed(ctx)
+	if err != nil {
+		return defaultPropagationEstimate, err
+	}
+	if len(lookup.Records) == 0 {
+		return defaultPropagationEstimate, nil
+	}
+
+	return lookup.TTL + propagationMargin, nil
+}
@@ -0,0 +1,121 @@
+package duckdns
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestLookupTXTDetailedAt(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("example.duckdns.org", "value")
+	zone.setTTL(60 * time.Second)
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+
+	lookup, err := c.LookupTXTDetailedAt(context.Background(), zone.addr)
+	if err != nil {
+		t.Fatalf("LookupTXTDetailedAt() error = %v", err)
+	}
+	if len(lookup.Records) != 1 || lookup.Records[0] != "value" {
+		t.Errorf("Records = %q, want [value]", lookup.Records)
+	}
+	if lookup.TTL != 60*time.Second {
+		t.Errorf("TTL = %v, want 1m0s", lookup.TTL)
+	}
+	if lookup.Server != zone.addr || lookup.Latency <= 0 {
+		t.Errorf("Server = %q, Latency = %v, want %q and a positive latency", lookup.Server, lookup.Latency, zone.addr)
+	}
+}
+
+func TestLookupTXTDetailedAtTruncated(t *testing.T) {
+	zone := newFakeZone(t)
+	values := make([]string, 8)
+	for i := range values {
+		values[i] = strings.Repeat(string(rune('a'+i)), 200)
+	}
+	zone.setTXT("example.duckdns.org", values...)
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+
+	lookup, err := c.LookupTXTDetailedAt(context.Background(), zone.addr)
+	if err != nil {
+		t.Fatalf("LookupTXTDetailedAt() error = %v", err)
+	}
+	if len(lookup.Records) != len(values) {
+		t.Errorf("Records = %d, want %d retried over tcp", len(lookup.Records), len(values))
+	}
+	if got := zone.count(dns.TypeTXT, "example.duckdns.org"); got != 2 {
+		t.Errorf("TXT queries = %d, want 2", got)
+	}
+}
+
+func TestLookupTXTDetailedAtNotFound(t *testing.T) {
+	zone := newFakeZone(t)
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+
+	lookup, err := c.LookupTXTDetailedAt(context.Background(), zone.addr)
+	if err != nil || len(lookup.Records) != 0 {
+		t.Fatalf("LookupTXTDetailedAt() = %+v, %v, want no records", lookup, err)
+	}
+}
+
+func TestLookupTXTDetailed(t *testing.T) {
+	broken := newFakeZone(t)
+	broken.setRcode("example.duckdns.org", dns.RcodeServerFailure)
+	zone := newFakeZone(t)
+	zone.setTXT("example.duckdns.org", "value")
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"),
+		WithAuthoritativeServers([]string{broken.addr, zone.addr}))
+
+	lookup, err := c.LookupTXTDetailed(context.Background())
+	if err != nil {
+		t.Fatalf("LookupTXTDetailed() error = %v", err)
+	}
+	if lookup.Server != zone.addr {
+		t.Errorf("Server = %q, want the second server %q", lookup.Server, zone.addr)
+	}
+}
+
+func TestWithAuthoritativeServersEmpty(t *testing.T) {
+	if err := WithAuthoritativeServers(nil)(&ClientC{}); err == nil {
+		t.Error("WithAuthoritativeServers(nil) error = nil, want an error")
+	}
+}
+
+func TestEstimatePropagation(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("example.duckdns.org", "value")
+	zone.setTTL(60 * time.Second)
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithAuthoritativeServers([]string{zone.addr}))
+
+	got, err := c.EstimatePropagation(context.Background())
+	if err != nil {
+		t.Fatalf("EstimatePropagation() error = %v", err)
+	}
+	if want := 60*time.Second + propagationMargin; got != want {
+		t.Errorf("EstimatePropagation() = %v, want %v", got, want)
+	}
+}
+
+func TestEstimatePropagationDefault(t *testing.T) {
+	zone := newFakeZone(t)
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithAuthoritativeServers([]string{zone.addr}))
+
+	got, err := c.EstimatePropagation(context.Background())
+	if err != nil || got != defaultPropagationEstimate {
+		t.Errorf("EstimatePropagation() = %v, %v, want the default without a record", got, err)
+	}
+
+	zone.setRcode("example.duckdns.org", dns.RcodeServerFailure)
+	got, err = c.EstimatePropagation(context.Background())
+	if err == nil || got != defaultPropagationEstimate {
+		t.Errorf("EstimatePropagation() = %v, %v, want the default and the lookup error", got, err)
+	}
+}
@@ -0,0 +1,56 @@
+package duckdns
+
+import (
+	"context"
+	"slices"
+	"sync"
+)
+
+// domainLocks serializes the operations on a same duckdns domain while
+// letting different domains proceed in parallel
+type domainLocks struct {
+	mu    sync.Mutex
+	locks map[string]chan struct{}
+}
+
+func (l *domainLocks) get(domain string) chan struct{} {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.locks == nil {
+		l.locks = make(map[string]chan struct{})
+	}
+	lock, ok := l.locks[domain]
+	if !ok {
+		lock = make(chan struct{}, 1)
+		l.locks[domain] = lock
+	}
+	return lock
+}
+
+// lock acquires the locks of domains in sorted order, so that overlapping
+// sets can't deadlock, and returns the function releasing them
+func (l *domainLocks) lock(ctx context.Context, domains []string) (func(), error) {
+	sorted := slices.Clone(domains)
+	slices.Sort(sorted)
+	sorted = slices.Compact(sorted)
+
+	held := make([]chan struct{}, 0, len(sorted))
+	unlock := func() {
+		for i := len(held) - 1; i >= 0; i-- {
+			<-held[i]
+		}
+	}
+
+	for _, domain := range sorted {
+		lock := l.get(domain)
+		select {
+		case lock <- struct{}{}:
+			held = append(held, lock)
+		case <-ctx.Done():
+			unlock()
+			return nil, ctx.Err()
+		}
+	}
+	return unlock, nil
+}
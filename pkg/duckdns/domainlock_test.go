@@ -0,0 +1,141 @@
+package duckdns
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// overlapTracker is a handler recording the requests it serves at once for
+// each domains parameter, and the start and end of every operation
+type overlapTracker struct {
+	mu       sync.Mutex
+	inflight map[string]int
+	peak     map[string]int
+	ops      []string
+	total    int
+	maxTotal int
+}
+
+func (o *overlapTracker) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	domains := q.Get("domains")
+	op := "update"
+	if q.Get("clear") == "true" {
+		op = "clear"
+	}
+
+	o.mu.Lock()
+	o.inflight[domains]++
+	o.peak[domains] = max(o.peak[domains], o.inflight[domains])
+	o.total++
+	o.maxTotal = max(o.maxTotal, o.total)
+	o.ops = append(o.ops, op+" start")
+	o.mu.Unlock()
+
+	time.Sleep(10 * time.Millisecond)
+
+	o.mu.Lock()
+	o.inflight[domains]--
+	o.total--
+	o.ops = append(o.ops, op+" end")
+	o.mu.Unlock()
+	w.Write([]byte("OK"))
+}
+
+func newOverlapTracker() *overlapTracker {
+	return &overlapTracker{inflight: make(map[string]int), peak: make(map[string]int)}
+}
+
+func TestDomainLocksSerializeSameDomain(t *testing.T) {
+	tracker := newOverlapTracker()
+	c := newTestClient(t, newTestConfig(), tracker.ServeHTTP)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			var err error
+			if i%2 == 0 {
+				_, err = c.UpdateRecord(context.Background(), "value")
+			} else {
+				_, err = c.ClearRecord(context.Background(), "value")
+			}
+			if err != nil {
+				t.Errorf("operation %d error = %v", i, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	if got := tracker.peak["example"]; got != 1 {
+		t.Errorf("peak concurrent operations on example = %d, want 1", got)
+	}
+	if got := len(tracker.ops); got != 16 {
+		t.Fatalf("operation events = %d, want 16", got)
+	}
+	// every operation ends before the next one starts
+	for i := 0; i < len(tracker.ops); i += 2 {
+		start, end := tracker.ops[i], tracker.ops[i+1]
+		if !strings.HasSuffix(start, " start") || end != strings.TrimSuffix(start, " start")+" end" {
+			t.Errorf("events %q, %q, want an operation start followed by its end", start, end)
+		}
+	}
+}
+
+func TestDomainLocksParallelDomains(t *testing.T) {
+	tracker := newOverlapTracker()
+	c := newTestClient(t, newTestConfig("first", "second"), tracker.ServeHTTP)
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			domain := []string{"first", "second"}[i%2]
+			if _, err := c.UpdateRecordForDomain(context.Background(), domain, "value"); err != nil {
+				t.Errorf("UpdateRecordForDomain(%v) error = %v", domain, err)
+			}
+		}(i)
+	}
+	wg.Wait()
+
+	tracker.mu.Lock()
+	defer tracker.mu.Unlock()
+	for _, domain := range []string{"first", "second"} {
+		if got := tracker.peak[domain]; got != 1 {
+			t.Errorf("peak concurrent operations on %v = %d, want 1", domain, got)
+		}
+	}
+	if tracker.maxTotal != 2 {
+		t.Errorf("peak concurrent operations = %d, want both domains in parallel", tracker.maxTotal)
+	}
+}
+
+func TestDomainLocksCanceled(t *testing.T) {
+	var locks domainLocks
+	unlock, err := locks.lock(context.Background(), []string{"a", "b"})
+	if err != nil {
+		t.Fatalf("lock() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := locks.lock(ctx, []string{"b", "c"}); err == nil {
+		t.Fatal("lock() of a held domain error = nil, want the context error")
+	}
+
+	// the canceled attempt released what it held
+	unlock()
+	unlock2, err := locks.lock(context.Background(), []string{"c", "b", "a"})
+	if err != nil {
+		t.Fatalf("lock() error = %v", err)
+	}
+	unlock2()
+}
@@ -0,0 +1,56 @@
+package duckdns
+
+import "strings"
+
+// DomainError structure associating the failure of a batch operation with
+// the domain it happened for
+type DomainError struct {
+	Domain string
+	Err    error
+}
+
+func (e *DomainError) Error() string {
+	return e.Domain + ": " + e.Err.Error()
+}
+
+func (e *DomainError) Unwrap() error {
+	return e.Err
+}
+
+// MultiError structure containing the per-domain failures of an operation
+// fanning out over several requests or lookups, errors.Is and errors.As
+// inspect every one of them
+type MultiError struct {
+	Errors []*DomainError
+}
+
+func (e *MultiError) Error() string {
+	msgs := make([]string, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		msgs = append(msgs, err.Error())
+	}
+	return strings.Join(msgs, "; ")
+}
+
+func (e *MultiError) Unwrap() []error {
+	errs := make([]error, 0, len(e.Errors))
+	for _, err := range e.Errors {
+		errs = append(errs, err)
+	}
+	return errs
+}
+
+// add records the failure of domain, it does nothing for a nil err
+func (e *MultiError) add(domain string, err error) {
+	if err != nil {
+		e.Errors = append(e.Errors, &DomainError{Domain: domain, Err: err})
+	}
+}
+
+// errOrNil returns e, or nil when no failure was recorded
+func (e *MultiError) errOrNil() error {
+	if len(e.Errors) == 0 {
+		return nil
+	}
+	return e
+}
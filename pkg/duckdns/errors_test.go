@@ -0,0 +1,78 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+func TestMultiError(t *testing.T) {
+	timeout := errors.New("timeout")
+	status := &StatusError{StatusCode: http.StatusBadGateway, Status: "502 Bad Gateway"}
+
+	var errs MultiError
+	errs.add("first", nil)
+	if err := errs.errOrNil(); err != nil {
+		t.Fatalf("errOrNil() = %v without failures, want nil", err)
+	}
+	errs.add("first", timeout)
+	errs.add("second", status)
+
+	err := errs.errOrNil()
+	if want := "first: timeout; second: unexpected status 502 Bad Gateway"; err.Error() != want {
+		t.Errorf("Error() = %q, want %q", err, want)
+	}
+	if !errors.Is(err, timeout) {
+		t.Errorf("errors.Is(%v, timeout) = false, want true", err)
+	}
+
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadGateway {
+		t.Errorf("errors.As() = %v, want the 502 status error", statusErr)
+	}
+	var domainErr *DomainError
+	if !errors.As(err, &domainErr) || domainErr.Domain != "first" {
+		t.Errorf("errors.As() = %v, want the failure of first", domainErr)
+	}
+}
+
+func TestMultiErrorBatchCauses(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(func(w http.ResponseWriter, r *http.Request) {
+		switch r.URL.Query().Get("domains") {
+		case "rejected":
+			w.Write([]byte("KO"))
+		case "invalid":
+			w.WriteHeader(http.StatusBadRequest)
+		default:
+			w.Write([]byte("OK"))
+		}
+	}))
+
+	err := c.UpdateRecordsPerDomain(context.Background(), map[string]string{
+		"good":     "one",
+		"rejected": "two",
+		"invalid":  "three",
+	})
+
+	var multi *MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 2 {
+		t.Fatalf("UpdateRecordsPerDomain() error = %v, want two failures", err)
+	}
+	if !errors.Is(err, ErrRequestRejected) {
+		t.Errorf("UpdateRecordsPerDomain() error = %v, want it to wrap %v", err, ErrRequestRejected)
+	}
+	var statusErr *StatusError
+	if !errors.As(err, &statusErr) || statusErr.StatusCode != http.StatusBadRequest {
+		t.Errorf("UpdateRecordsPerDomain() error = %v, want it to wrap the 400 status error", err)
+	}
+
+	causes := make(map[string]error)
+	for _, domainErr := range multi.Errors {
+		causes[domainErr.Domain] = domainErr.Err
+	}
+	if !errors.Is(causes["rejected"], ErrRequestRejected) || errors.Is(causes["invalid"], ErrRequestRejected) {
+		t.Errorf("per domain causes = %v, want the rejection on rejected only", causes)
+	}
+}
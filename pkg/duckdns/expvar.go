@@ -0,0 +1,61 @@
+package duckdns
+
+import (
+	"errors"
+	"expvar"
+	"fmt"
+	"sync"
+)
+
+// expvarMu serializes the publication of the expvar maps of WithExpvar
+var expvarMu sync.Mutex
+
+// WithExpvar option to publish the request, error, retry and KO counters and
+// the last error of the client as an expvar map of the given name. Clients
+// built with the same name share the map and add to its counters. The token
+// is masked in the last error.
+func WithExpvar(name string) Option {
+	return func(c *ClientC) error {
+		if name == "" {
+			return errors.New("expvar name must be non-empty")
+		}
+
+		expvarMu.Lock()
+		defer expvarMu.Unlock()
+
+		switch v := expvar.Get(name).(type) {
+		case nil:
+			c.vars = expvar.NewMap(name)
+			for _, key := range []string{"requests", "errors", "retries", "koResponses"} {
+				c.vars.Add(key, 0)
+			}
+			c.vars.Set("lastError", new(expvar.String))
+		case *expvar.Map:
+			c.vars = v
+		default:
+			return fmt.Errorf("expvar %q is already published and is not a map", name)
+		}
+		return nil
+	}
+}
+
+// recordVars counts an update request in the published expvar map, if any
+func (c *ClientC) recordVars(response *Response, err error) {
+	if c.vars == nil {
+		return
+	}
+
+	c.vars.Add("requests", 1)
+	if response != nil && response.Attempts > 1 {
+		c.vars.Add("retries", int64(response.Attempts-1))
+	}
+	if err != nil {
+		c.vars.Add("errors", 1)
+		lastError := new(expvar.String)
+		lastError.Set(c.obfuscate(err.Error()))
+		c.vars.Set("lastError", lastError)
+	}
+	if errors.Is(err, ErrRequestRejected) {
+		c.vars.Add("koResponses", 1)
+	}
+}
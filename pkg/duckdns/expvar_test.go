@@ -0,0 +1,105 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"expvar"
+	"fmt"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+// expvarName returns an expvar name unique to the test run, published names
+// can't be removed
+func expvarName(t *testing.T) string {
+	return fmt.Sprintf("duckdns_%s_%p", t.Name(), t)
+}
+
+// expvarInt returns the integer counter key of the expvar map name
+func expvarInt(t *testing.T, name, key string) int64 {
+	t.Helper()
+	v, ok := expvar.Get(name).(*expvar.Map).Get(key).(*expvar.Int)
+	if !ok {
+		t.Fatalf("expvar %s.%s is not published", name, key)
+	}
+	return v.Value()
+}
+
+func TestWithExpvar(t *testing.T) {
+	name := expvarName(t)
+	c := newTestClient(t, newTestConfig(), sequence(
+		respond(http.StatusOK, "OK"),
+		respond(http.StatusOK, "KO"),
+		respond(http.StatusServiceUnavailable, ""),
+		respond(http.StatusOK, "OK"),
+	), WithExpvar(name))
+
+	for i := 0; i < 3; i++ {
+		c.UpdateRecord(context.Background(), "value")
+	}
+
+	want := map[string]int64{"requests": 3, "errors": 1, "retries": 1, "koResponses": 1}
+	for key, value := range want {
+		if got := expvarInt(t, name, key); got != value {
+			t.Errorf("expvar %s = %d, want %d", key, got, value)
+		}
+	}
+	lastError := expvar.Get(name).(*expvar.Map).Get("lastError").String()
+	if !strings.Contains(lastError, ErrRequestRejected.Error()) {
+		t.Errorf("lastError = %s, want the rejection", lastError)
+	}
+}
+
+func TestWithExpvarMasksToken(t *testing.T) {
+	name := expvarName(t)
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithExpvar(name))
+	c.ResponseValidator = func(*Response) error {
+		return fmt.Errorf("unexpected answer to token %s", testToken)
+	}
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err == nil {
+		t.Fatal("UpdateRecord() error = nil, want the validator error")
+	}
+	published := expvar.Get(name).String()
+	if strings.Contains(published, testToken) || !strings.Contains(published, obfuscatedToken) {
+		t.Errorf("expvar %s = %s, want the token masked", name, published)
+	}
+}
+
+func TestWithExpvarShared(t *testing.T) {
+	name := expvarName(t)
+	first := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithExpvar(name))
+	second := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithExpvar(name))
+
+	for _, c := range []*ClientC{first, second} {
+		if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+			t.Fatalf("UpdateRecord() error = %v", err)
+		}
+	}
+	if got := expvarInt(t, name, "requests"); got != 2 {
+		t.Errorf("expvar requests = %d, want 2", got)
+	}
+}
+
+func TestWithExpvarInvalid(t *testing.T) {
+	name := expvarName(t)
+	expvar.NewInt(name)
+
+	if err := WithExpvar(name)(&ClientC{}); err == nil {
+		t.Errorf("WithExpvar(%q) of an int error = nil, want an error", name)
+	}
+	if err := WithExpvar("")(&ClientC{}); err == nil {
+		t.Error("WithExpvar(\"\") error = nil, want an error")
+	}
+}
+
+func TestWithoutExpvar(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "KO"))
+	if _, err := c.UpdateRecord(context.Background(), "value"); !errors.Is(err, ErrRequestRejected) {
+		t.Fatalf("UpdateRecord() error = %v, want %v", err, ErrRequestRejected)
+	}
+	if c.vars != nil {
+		t.Error("expvar map published without WithExpvar")
+	}
+}
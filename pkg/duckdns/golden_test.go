@@ -0,0 +1,110 @@
+package duckdns
+
+import (
+	"context"
+	"flag"
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+var update = flag.Bool("update", false, "rewrite the golden files of testdata")
+
+func TestCanonicalQueryGolden(t *testing.T) {
+	tests := []struct {
+		name    string
+		verbose bool
+		call    func(c *ClientC) error
+	}{
+		{
+			name: "update_ip",
+			call: func(c *ClientC) error { _, err := c.UpdateIP(context.Background()); return err },
+		},
+		{
+			name: "update_ip_values",
+			call: func(c *ClientC) error {
+				_, err := c.UpdateIPWithValues(context.Background(), "203.0.113.7", "")
+				return err
+			},
+		},
+		{
+			name: "update_ip_values_ipv6",
+			call: func(c *ClientC) error {
+				_, err := c.UpdateIPWithValues(context.Background(), "203.0.113.7", "2001:db8::1")
+				return err
+			},
+		},
+		{
+			name: "update_ip_dual_stack",
+			call: func(c *ClientC) error { _, err := c.UpdateIPAutoDualStack(context.Background()); return err },
+		},
+		{
+			name: "clear_ip",
+			call: func(c *ClientC) error { _, err := c.ClearIP(context.Background()); return err },
+		},
+		{
+			name: "update_record",
+			call: func(c *ClientC) error { _, err := c.UpdateRecord(context.Background(), "value"); return err },
+		},
+		{
+			name:    "update_record_verbose",
+			verbose: true,
+			call:    func(c *ClientC) error { _, err := c.UpdateRecord(context.Background(), "value"); return err },
+		},
+		{
+			name: "update_record_domain",
+			call: func(c *ClientC) error {
+				_, err := c.UpdateRecordForDomain(context.Background(), "second", "value")
+				return err
+			},
+		},
+		{
+			name: "clear_record",
+			call: func(c *ClientC) error { _, err := c.ClearRecord(context.Background(), "value"); return err },
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig("first", "second")
+			config.Verbose = tt.verbose
+			var log requestLog
+			c := newTestClient(t, config, log.wrap(respond(http.StatusOK, "OK")))
+
+			if err := tt.call(c); err != nil {
+				t.Fatalf("%s error = %v", tt.name, err)
+			}
+			got := canonicalQuery(log.last(t).URL.RawQuery) + "\n"
+
+			golden := filepath.Join("testdata", tt.name+".golden")
+			if *update {
+				if err := os.WriteFile(golden, []byte(got), 0o644); err != nil {
+					t.Fatal(err)
+				}
+			}
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("unable to read golden file: %v", err)
+			}
+			if got != string(want) {
+				t.Errorf("canonical query =\n%s\nwant (%s)\n%s", got, golden, want)
+			}
+		})
+	}
+}
+
+func TestCanonicalQueryStable(t *testing.T) {
+	c := newTestClient(t, newTestConfig("first", "second"), respond(http.StatusOK, "OK"))
+
+	params := map[string][]string{"txt": {"a&b=c"}, "clear": {"true"}}
+	first := c.buildCanonicalQuery(params)
+	for i := 0; i < 10; i++ {
+		if got := c.buildCanonicalQuery(params); got != first {
+			t.Fatalf("buildCanonicalQuery() = %q, then %q", first, got)
+		}
+	}
+	if want := "clear=true&domains=first,second&token=" + obfuscatedToken + "&txt=a%26b%3Dc"; first != want {
+		t.Errorf("buildCanonicalQuery() = %q, want %q", first, want)
+	}
+}
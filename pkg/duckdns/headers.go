@@ -0,0 +1,87 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+// validateHeader rejects header names and values that could inject extra
+// headers or break the request
+func validateHeader(key, value string) error {
+	if key == "" {
+		return errors.New("header name must be non-empty")
+	}
+	if strings.ContainsFunc(key, func(r rune) bool { return r <= ' ' || r >= 0x7f || r == ':' }) {
+		return fmt.Errorf("invalid header name %q", key)
+	}
+	if strings.ContainsFunc(value, func(r rune) bool { return (r < ' ' && r != '\t') || r == 0x7f }) {
+		return fmt.Errorf("invalid value for header %q", key)
+	}
+	return nil
+}
+
+// SetHeader function to attach an extra header to every request, a
+// User-Agent header overrides the client UserAgent. It is safe to call while
+// requests are in flight, which keep the headers they started with.
+func (c *ClientC) SetHeader(key, value string) error {
+	if err := validateHeader(key, value); err != nil {
+		return err
+	}
+
+	c.headersMu.Lock()
+	defer c.headersMu.Unlock()
+
+	// copy on write, so that the snapshots handed to requests never change
+	headers := c.headers.Clone()
+	if headers == nil {
+		headers = make(http.Header)
+	}
+	headers.Set(key, value)
+	c.headers = headers
+	return nil
+}
+
+// headerSnapshot returns the extra headers, which must not be modified
+func (c *ClientC) headerSnapshot() http.Header {
+	c.headersMu.RLock()
+	defer c.headersMu.RUnlock()
+	return c.headers
+}
+
+// WithHeader option to attach an extra header to every request
+func WithHeader(key, value string) Option {
+	return func(c *ClientC) error {
+		return c.SetHeader(key, value)
+	}
+}
+
+// userAgentSuffixKey carries the User-Agent suffix of a request
+type userAgentSuffixKey struct{}
+
+// ContextWithUserAgentSuffix function to return a context whose requests send
+// the client UserAgent followed by suffix, e.g. to identify a tenant or
+// issuer. Control characters are removed from the suffix.
+func ContextWithUserAgentSuffix(ctx context.Context, suffix string) context.Context {
+	suffix = strings.Map(func(r rune) rune {
+		if r < ' ' || r == 0x7f {
+			return -1
+		}
+		return r
+	}, suffix)
+	return context.WithValue(ctx, userAgentSuffixKey{}, strings.TrimSpace(suffix))
+}
+
+// userAgent returns the User-Agent of a request, composed without touching
+// the shared client state
+func (c *ClientC) userAgent(ctx context.Context) string {
+	if ctx == nil {
+		return c.UserAgent
+	}
+	if suffix, _ := ctx.Value(userAgentSuffixKey{}).(string); suffix != "" {
+		return c.UserAgent + " " + suffix
+	}
+	return c.UserAgent
+}
@@ -0,0 +1,183 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+)
+
+func TestWithHeader(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")), WithHeader("X-Api-Key", "secret"))
+	if err := c.SetHeader("X-Tenant", "team-a"); err != nil {
+		t.Fatalf("SetHeader() error = %v", err)
+	}
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+
+	req := log.last(t)
+	for key, want := range map[string]string{"X-Api-Key": "secret", "X-Tenant": "team-a", "User-Agent": defaultUserAgent} {
+		if got := req.Header.Get(key); got != want {
+			t.Errorf("header %s = %q, want %q", key, got, want)
+		}
+	}
+}
+
+func TestSetHeaderOverridesUserAgent(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")), WithHeader("User-Agent", "custom/1.0"))
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if got := log.last(t).Header.Get("User-Agent"); got != "custom/1.0" {
+		t.Errorf("User-Agent = %q, want custom/1.0", got)
+	}
+}
+
+func TestSetHeaderRejectsInjection(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+
+	tests := []struct{ key, value string }{
+		{"", "value"},
+		{"X-Bad Name", "value"},
+		{"X-Bad:Name", "value"},
+		{"X-Header", "value\r\nX-Injected: 1"},
+		{"X-Header", "value\n"},
+		{"X-Header", "value\x00"},
+	}
+	for _, tt := range tests {
+		if err := c.SetHeader(tt.key, tt.value); err == nil {
+			t.Errorf("SetHeader(%q, %q) error = nil, want an error", tt.key, tt.value)
+		}
+	}
+	if len(c.headerSnapshot()) != 0 {
+		t.Errorf("headers = %v, want none set", c.headerSnapshot())
+	}
+}
+
+func TestSetHeaderConcurrentRequests(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func(i int) {
+			defer wg.Done()
+			if err := c.SetHeader("X-Request", strconv.Itoa(i)); err != nil {
+				t.Errorf("SetHeader() error = %v", err)
+			}
+		}(i)
+		go func() {
+			defer wg.Done()
+			if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+				t.Errorf("UpdateRecord() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+}
+
+func TestContextWithUserAgentSuffix(t *testing.T) {
+	tests := []struct {
+		suffix string
+		want   string
+	}{
+		{suffix: "issuer/letsencrypt", want: defaultUserAgent + " issuer/letsencrypt"},
+		{suffix: "tenant\r\nX-Injected: 1", want: defaultUserAgent + " tenantX-Injected: 1"},
+		{suffix: " \t", want: defaultUserAgent},
+	}
+
+	for _, tt := range tests {
+		var log requestLog
+		c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")))
+
+		ctx := ContextWithUserAgentSuffix(context.Background(), tt.suffix)
+		if _, err := c.UpdateRecord(ctx, "value"); err != nil {
+			t.Fatalf("UpdateRecord() error = %v", err)
+		}
+		if got := log.last(t).UserAgent(); got != tt.want {
+			t.Errorf("User-Agent with suffix %q = %q, want %q", tt.suffix, got, tt.want)
+		}
+		if c.UserAgent != defaultUserAgent {
+			t.Errorf("client UserAgent = %q, want it unchanged", c.UserAgent)
+		}
+	}
+}
+
+func TestRequestSigner(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")))
+	c.SetHeader("X-Custom", "custom")
+	c.RequestSigner = func(req *http.Request) error {
+		// headers are set before signing
+		req.Header.Set("X-Signature", "signed-"+req.Header.Get("X-Custom"))
+		return nil
+	}
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if got := log.last(t).Header.Get("X-Signature"); got != "signed-custom" {
+		t.Errorf("X-Signature = %q, want signed-custom", got)
+	}
+}
+
+func TestRequestSignerError(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")))
+	errSign := errors.New("no signing key")
+	c.RequestSigner = func(req *http.Request) error { return errSign }
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); !errors.Is(err, errSign) {
+		t.Fatalf("UpdateRecord() error = %v, want %v", err, errSign)
+	}
+	if got := log.count(); got != 0 {
+		t.Errorf("requests = %d, want 0", got)
+	}
+}
+
+func TestRequestInterceptor(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")))
+	var seen []string
+	c.RequestInterceptor = func(req *http.Request) error {
+		seen = append(seen, req.URL.Query().Get("txt"))
+		req.Header.Set("X-Audited", "true")
+		return nil
+	}
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if len(seen) != 1 || seen[0] != "value" {
+		t.Errorf("intercepted txt = %q, want [value]", seen)
+	}
+	if got := log.last(t).Header.Get("X-Audited"); got != "true" {
+		t.Errorf("X-Audited = %q, want the header set by the interceptor", got)
+	}
+}
+
+func TestRequestInterceptorAbort(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")))
+	errPolicy := errors.New("txt updates are not allowed")
+	c.RequestInterceptor = func(req *http.Request) error { return errPolicy }
+
+	_, err := c.UpdateRecord(context.Background(), "value")
+	if !errors.Is(err, errPolicy) {
+		t.Fatalf("UpdateRecord() error = %v, want %v", err, errPolicy)
+	}
+	if strings.Contains(err.Error(), testToken) {
+		t.Errorf("error %q holds the token", err)
+	}
+	if got := log.count(); got != 0 {
+		t.Errorf("requests = %d, want 0", got)
+	}
+}
@@ -0,0 +1,42 @@
+package duckdns
+
+import (
+	"sync"
+	"time"
+)
+
+// outcomes tracks the time of the last successful and failed request
+type outcomes struct {
+	mu          sync.RWMutex
+	lastSuccess time.Time
+	lastErrorAt time.Time
+	lastErr     error
+}
+
+// record stores the outcome of a request completed at now
+func (o *outcomes) record(now time.Time, err error) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+
+	if err != nil {
+		o.lastErrorAt, o.lastErr = now, err
+		return
+	}
+	o.lastSuccess = now
+}
+
+// LastSuccess function to return when a request last succeeded, the zero
+// time when none did yet
+func (c *ClientC) LastSuccess() time.Time {
+	c.outcomes.mu.RLock()
+	defer c.outcomes.mu.RUnlock()
+	return c.outcomes.lastSuccess
+}
+
+// LastError function to return when a request last failed and its error, the
+// zero time and a nil error when none did yet
+func (c *ClientC) LastError() (time.Time, error) {
+	c.outcomes.mu.RLock()
+	defer c.outcomes.mu.RUnlock()
+	return c.outcomes.lastErrorAt, c.outcomes.lastErr
+}
@@ -0,0 +1,47 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestLastSuccessAndError(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), sequence(respond(http.StatusOK, "OK"), respond(http.StatusOK, "KO"), respond(http.StatusOK, "OK")))
+
+	if at, err := c.LastError(); !at.IsZero() || err != nil || !c.LastSuccess().IsZero() {
+		t.Fatalf("outcomes before any request = %v, %v, %v, want zero values", c.LastSuccess(), at, err)
+	}
+
+	before := time.Now()
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	success := c.LastSuccess()
+	if success.Before(before) {
+		t.Errorf("LastSuccess() = %v, want after %v", success, before)
+	}
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err == nil {
+		t.Fatal("UpdateRecord() error = nil, want a rejection")
+	}
+	at, err := c.LastError()
+	if at.Before(success) || !errors.Is(err, ErrRequestRejected) {
+		t.Errorf("LastError() = %v, %v, want a rejection after %v", at, err, success)
+	}
+	if !c.LastSuccess().Equal(success) {
+		t.Errorf("LastSuccess() = %v after a failure, want it unchanged", c.LastSuccess())
+	}
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if !c.LastSuccess().After(at) {
+		t.Errorf("LastSuccess() = %v, want after the failure at %v", c.LastSuccess(), at)
+	}
+	if errAt, _ := c.LastError(); !errAt.Equal(at) {
+		t.Errorf("LastError() at %v after a success, want it unchanged", errAt)
+	}
+}
@@ -0,0 +1,406 @@
+package duckdns
+
+import (
+	"bytes"
+	"flag"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+	"k8s.io/klog/v2"
+)
+
+// testToken has the uuid shape of a duckdns token
+const testToken = "01234567-89ab-cdef-0123-456789abcdef"
+
+// newTestConfig returns a valid configuration for the given domains
+func newTestConfig(domains ...string) *ConfigC {
+	if len(domains) == 0 {
+		domains = []string{"example"}
+	}
+	return &ConfigC{DomainNames: domains, Token: testToken}
+}
+
+// newTestClient returns a client of config sending its requests to a test
+// server running handler, with retries fast enough for tests
+func newTestClient(t *testing.T, config *ConfigC, handler http.HandlerFunc, opts ...Option) *ClientC {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	c := NewClient(srv.Client(), config, opts...)
+	c.BaseURL = srv.URL
+	c.Retry.BaseDelay = time.Millisecond
+	c.Retry.MaxDelay = 5 * time.Millisecond
+	return c
+}
+
+// respond returns a handler answering every request with status and body
+func respond(status int, body string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(status)
+		w.Write([]byte(body))
+	}
+}
+
+// roundTripFunc is an http.RoundTripper answering with a function
+type roundTripFunc func(req *http.Request) (*http.Response, error)
+
+func (f roundTripFunc) RoundTrip(req *http.Request) (*http.Response, error) {
+	return f(req)
+}
+
+// requestLog records the requests received by a test server
+type requestLog struct {
+	mu       sync.Mutex
+	requests []*http.Request
+	bodies   []string
+}
+
+// wrap returns a handler recording every request before passing it to next
+func (l *requestLog) wrap(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+
+		l.mu.Lock()
+		l.requests = append(l.requests, r)
+		l.bodies = append(l.bodies, string(body))
+		l.mu.Unlock()
+
+		next(w, r)
+	}
+}
+
+// count returns the number of requests received
+func (l *requestLog) count() int {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return len(l.requests)
+}
+
+// last returns the latest request received
+func (l *requestLog) last(t *testing.T) *http.Request {
+	t.Helper()
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	if len(l.requests) == 0 {
+		t.Fatal("no request received")
+	}
+	return l.requests[len(l.requests)-1]
+}
+
+// sequence returns a handler answering the requests with the given handlers
+// in turn, the last one answering every request past the end
+func sequence(handlers ...http.HandlerFunc) http.HandlerFunc {
+	var mu sync.Mutex
+	next := 0
+	return func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		h := handlers[min(next, len(handlers)-1)]
+		next++
+		mu.Unlock()
+
+		h(w, r)
+	}
+}
+
+// fakeZone is a programmable dns server answering A, AAAA, TXT and NS
+// queries from its records, any other name is NXDOMAIN
+type fakeZone struct {
+	addr string
+
+	mu      sync.Mutex
+	txt     map[string][][]string
+	a       map[string][]string
+	aaaa    map[string][]string
+	ns      map[string][]string
+	rcodes  map[string]int
+	ttl     uint32
+	delay   time.Duration
+	queries []dns.Question
+
+	inflight, maxInflight int
+}
+
+// newFakeZone starts a fake dns server listening on a local udp and tcp port,
+// stopped at the end of the test
+func newFakeZone(t *testing.T) *fakeZone {
+	t.Helper()
+
+	z := &fakeZone{
+		txt:    make(map[string][][]string),
+		a:      make(map[string][]string),
+		aaaa:   make(map[string][]string),
+		ns:     make(map[string][]string),
+		rcodes: make(map[string]int),
+		ttl:    300,
+	}
+	z.addr = serveDNS(t, z)
+	return z
+}
+
+// serveDNS starts a dns server running handler on a local udp and tcp port,
+// stopped at the end of the test, and returns its address
+func serveDNS(t *testing.T, handler dns.Handler) string {
+	t.Helper()
+
+	var pc net.PacketConn
+	var l net.Listener
+	for try := 0; l == nil; try++ {
+		var err error
+		if pc, err = net.ListenPacket("udp", "127.0.0.1:0"); err != nil {
+			t.Fatalf("unable to listen on udp: %v", err)
+		}
+		if l, err = net.Listen("tcp", pc.LocalAddr().String()); err != nil {
+			pc.Close()
+			if try == 5 {
+				t.Fatalf("unable to listen on tcp: %v", err)
+			}
+		}
+	}
+
+	for _, srv := range []*dns.Server{{PacketConn: pc, Handler: handler}, {Listener: l, Handler: handler}} {
+		started := make(chan struct{})
+		srv.NotifyStartedFunc = func() { close(started) }
+		go srv.ActivateAndServe()
+		<-started
+		t.Cleanup(func() { srv.Shutdown() })
+	}
+	return pc.LocalAddr().String()
+}
+
+// resolver returns a resolver sending every query to the fake server
+func (z *fakeZone) resolver() *net.Resolver {
+	return newAddrResolver(z.addr, nil)
+}
+
+// setTXT sets the TXT records of name, one record per value
+func (z *fakeZone) setTXT(name string, values ...string) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	records := make([][]string, 0, len(values))
+	for _, value := range values {
+		records = append(records, []string{value})
+	}
+	z.txt[zoneName(name)] = records
+}
+
+// setTXTStrings sets a single TXT record of name made of several
+// character-strings
+func (z *fakeZone) setTXTStrings(name string, strs ...string) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.txt[zoneName(name)] = [][]string{strs}
+}
+
+// setA sets the A records of name
+func (z *fakeZone) setA(name string, ips ...string) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.a[zoneName(name)] = ips
+}
+
+// setAAAA sets the AAAA records of name
+func (z *fakeZone) setAAAA(name string, ips ...string) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.aaaa[zoneName(name)] = ips
+}
+
+// setNS sets the NS records of name
+func (z *fakeZone) setNS(name string, hosts ...string) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.ns[zoneName(name)] = hosts
+}
+
+// setRcode makes every query for name fail with rcode, dns.RcodeSuccess
+// answers its records again
+func (z *fakeZone) setRcode(name string, rcode int) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	if rcode == dns.RcodeSuccess {
+		delete(z.rcodes, zoneName(name))
+		return
+	}
+	z.rcodes[zoneName(name)] = rcode
+}
+
+// setTTL sets the TTL of every answer
+func (z *fakeZone) setTTL(ttl time.Duration) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.ttl = uint32(ttl / time.Second)
+}
+
+// setDelay delays every answer by d
+func (z *fakeZone) setDelay(d time.Duration) {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	z.delay = d
+}
+
+// count returns the number of queries of type qtype received for name
+func (z *fakeZone) count(qtype uint16, name string) int {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+
+	n := 0
+	for _, q := range z.queries {
+		if q.Qtype == qtype && strings.EqualFold(q.Name, dns.Fqdn(name)) {
+			n++
+		}
+	}
+	return n
+}
+
+// peakInflight returns the largest number of queries served at once
+func (z *fakeZone) peakInflight() int {
+	z.mu.Lock()
+	defer z.mu.Unlock()
+	return z.maxInflight
+}
+
+func (z *fakeZone) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	m.Authoritative = true
+	m.RecursionAvailable = true
+
+	q := r.Question[0]
+	name := strings.ToLower(q.Name)
+	hdr := dns.RR_Header{Name: q.Name, Rrtype: q.Qtype, Class: dns.ClassINET}
+
+	z.mu.Lock()
+	z.queries = append(z.queries, q)
+	z.inflight++
+	z.maxInflight = max(z.maxInflight, z.inflight)
+	delay := z.delay
+	hdr.Ttl = z.ttl
+
+	_, known := z.txt[name]
+	for _, records := range []map[string][]string{z.a, z.aaaa, z.ns} {
+		if _, ok := records[name]; ok {
+			known = true
+		}
+	}
+
+	if rcode, ok := z.rcodes[name]; ok {
+		m.Rcode = rcode
+	} else if !known {
+		m.Rcode = dns.RcodeNameError
+	} else {
+		switch q.Qtype {
+		case dns.TypeTXT:
+			for _, strs := range z.txt[name] {
+				m.Answer = append(m.Answer, &dns.TXT{Hdr: hdr, Txt: strs})
+			}
+		case dns.TypeA:
+			for _, ip := range z.a[name] {
+				m.Answer = append(m.Answer, &dns.A{Hdr: hdr, A: net.ParseIP(ip)})
+			}
+		case dns.TypeAAAA:
+			for _, ip := range z.aaaa[name] {
+				m.Answer = append(m.Answer, &dns.AAAA{Hdr: hdr, AAAA: net.ParseIP(ip)})
+			}
+		case dns.TypeNS:
+			for _, host := range z.ns[name] {
+				m.Answer = append(m.Answer, &dns.NS{Hdr: hdr, Ns: dns.Fqdn(host)})
+			}
+		}
+	}
+	z.mu.Unlock()
+
+	time.Sleep(delay)
+	writeTruncated(w, r, m)
+
+	z.mu.Lock()
+	z.inflight--
+	z.mu.Unlock()
+}
+
+// writeTruncated writes the answer m to the query r, an answer too large for
+// the udp size of the query is truncated so that the client retries over tcp
+func writeTruncated(w dns.ResponseWriter, r, m *dns.Msg) {
+	if _, udp := w.RemoteAddr().(*net.UDPAddr); udp {
+		size := dns.MinMsgSize
+		if opt := r.IsEdns0(); opt != nil {
+			size = int(opt.UDPSize())
+		}
+		m.Truncate(size)
+	}
+	w.WriteMsg(m)
+}
+
+// zoneName returns the lowercased fully qualified form of name
+func zoneName(name string) string {
+	return strings.ToLower(dns.Fqdn(name))
+}
+
+// klogFlags are the klog flags, registered once as klog state is global
+var klogFlags = sync.OnceValue(func() *flag.FlagSet {
+	fs := flag.NewFlagSet("klog", flag.PanicOnError)
+	klog.InitFlags(fs)
+	return fs
+})
+
+// logBuffer collects log output safely across goroutines
+type logBuffer struct {
+	mu  sync.Mutex
+	buf bytes.Buffer
+}
+
+func (b *logBuffer) Write(p []byte) (int, error) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.Write(p)
+}
+
+// String returns the log output collected so far
+func (b *logBuffer) String() string {
+	klog.Flush()
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.buf.String()
+}
+
+// lines returns the collected log lines holding substr
+func (b *logBuffer) lines(substr string) []string {
+	var out []string
+	for _, line := range strings.Split(b.String(), "\n") {
+		if strings.Contains(line, substr) {
+			out = append(out, line)
+		}
+	}
+	return out
+}
+
+// captureLogs sends the klog output of the test at the given verbosity to the
+// returned buffer, each line written once with its severity header
+func captureLogs(t *testing.T, verbosity int) *logBuffer {
+	t.Helper()
+
+	fs := klogFlags()
+	fs.Set("v", strconv.Itoa(verbosity))
+	fs.Set("one_output", "true")
+
+	buf := &logBuffer{}
+	SetLogger(buf)
+	t.Cleanup(func() {
+		klog.Flush()
+		fs.Set("v", "0")
+		klog.SetOutput(io.Discard)
+	})
+	return buf
+}
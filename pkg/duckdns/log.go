@@ -0,0 +1,16 @@
+package duckdns
+
+import (
+	"io"
+
+	"k8s.io/klog/v2"
+)
+
+// SetLogger function to send the log output of the package to w, for
+// importers that use the client as a library without initializing klog flags.
+// klog logs to stderr when uninitialized, this redirects it instead; the
+// setting is process-wide as klog state is global.
+func SetLogger(w io.Writer) {
+	klog.LogToStderr(false)
+	klog.SetOutput(w)
+}
@@ -0,0 +1,104 @@
+package duckdns
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"strings"
+	"testing"
+
+	"k8s.io/klog/v2"
+)
+
+func TestLogVerbosityLevels(t *testing.T) {
+	tests := []struct {
+		verbosity   int
+		wantStart   bool
+		wantSuccess bool
+	}{
+		{verbosity: 0},
+		{verbosity: 2, wantStart: true},
+		{verbosity: 4, wantStart: true, wantSuccess: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(strings.Repeat("v", tt.verbosity+1), func(t *testing.T) {
+			logs := captureLogs(t, tt.verbosity)
+			c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+
+			if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+				t.Fatalf("UpdateRecord() error = %v", err)
+			}
+			if got := len(logs.lines("Sending GET request")) > 0; got != tt.wantStart {
+				t.Errorf("start logged = %v, want %v", got, tt.wantStart)
+			}
+			if got := len(logs.lines("succeeded")) > 0; got != tt.wantSuccess {
+				t.Errorf("success logged = %v, want %v", got, tt.wantSuccess)
+			}
+			if strings.Contains(logs.String(), testToken) {
+				t.Errorf("logs hold the token:\n%s", logs)
+			}
+		})
+	}
+}
+
+func TestLogErrorLevel(t *testing.T) {
+	logs := captureLogs(t, 0)
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "KO"))
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err == nil {
+		t.Fatal("UpdateRecord() error = nil, want a rejection")
+	}
+	got := logs.lines("Request to duckdns failed")
+	if len(got) != 1 || !strings.HasPrefix(got[0], "E") {
+		t.Fatalf("error lines = %q, want a single error", got)
+	}
+	if strings.Contains(got[0], testToken) {
+		t.Errorf("error line %q holds the token", got[0])
+	}
+}
+
+func TestSetLogger(t *testing.T) {
+	var buf logBuffer
+	SetLogger(&buf)
+	t.Cleanup(func() {
+		klog.Flush()
+		klog.SetOutput(io.Discard)
+	})
+
+	c := newTestClient(t, newTestConfig("first", "second"), respond(http.StatusOK, "OK"), WithLogPrefix("[test]"))
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if got := buf.lines("[test] Updating txt record of 2 domains"); len(got) == 0 {
+		t.Errorf("log output %q, want the warning written to the writer", buf.String())
+	}
+}
+
+func TestWithLogPrefix(t *testing.T) {
+	logs := captureLogs(t, 4)
+	c := newTestClient(t, newTestConfig(), sequence(respond(http.StatusOK, "OK"), respond(http.StatusOK, "KO")),
+		WithLogPrefix("[duckdns]"))
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if _, err := c.UpdateRecord(context.Background(), "value"); err == nil {
+		t.Fatal("UpdateRecord() error = nil, want a rejection")
+	}
+
+	for _, substr := range []string{"Sending GET request", "succeeded", "Request to duckdns failed"} {
+		got := logs.lines(substr)
+		if len(got) == 0 {
+			t.Errorf("no line holds %q", substr)
+		}
+		for _, line := range got {
+			if !strings.Contains(line, "] [duckdns] ") && !strings.Contains(line, `"[duckdns] `) {
+				t.Errorf("line %q is not prefixed", line)
+			}
+		}
+	}
+	if strings.Contains(logs.String(), testToken) {
+		t.Errorf("logs hold the token:\n%s", logs)
+	}
+}
@@ -0,0 +1,117 @@
+package duckdns
+
+import (
+	"fmt"
+	"net/url"
+	"strings"
+)
+
+// Option function to customize a duckdns client at construction time
+type Option func(c *ClientC) error
+
+// WithRetryOnEmptyResponse option to treat an empty response body from an
+// update operation as a retryable failure. Enabled by default.
+func WithRetryOnEmptyResponse(enabled bool) Option {
+	return func(c *ClientC) error {
+		c.Retry.RetryOnEmptyResponse = enabled
+		return nil
+	}
+}
+
+// WithFallbackBaseURL option to set a mirror of the duckdns api tried once
+// after the requests to BaseURL exhausted their retries
+func WithFallbackBaseURL(rawURL string) Option {
+	return func(c *ClientC) error {
+		u, err := url.Parse(rawURL)
+		if err != nil {
+			return fmt.Errorf("invalid fallback base url: %v", err)
+		}
+		if (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+			return fmt.Errorf("invalid fallback base url %q, expected http(s)://host", rawURL)
+		}
+
+		c.fallbackBaseURL = strings.TrimSuffix(rawURL, "/")
+		return nil
+	}
+}
+
+// defaultErrorBodySize is the body snippet length used by WithErrorBody when
+// no positive size is given
+const defaultErrorBodySize = 256
+
+// WithErrorBody option to include the first maxLen bytes of the response body,
+// with the token redacted, in the error of a rejected request
+func WithErrorBody(maxLen int) Option {
+	return func(c *ClientC) error {
+		if maxLen <= 0 {
+			maxLen = defaultErrorBodySize
+		}
+		c.errorBodySize = maxLen
+		return nil
+	}
+}
+
+// errorBody returns the redacted and truncated body used in errors
+func (c *ClientC) errorBody(body string) string {
+	body = c.obfuscate(strings.TrimSpace(body))
+	if len(body) > c.errorBodySize {
+		body = body[:c.errorBodySize] + "..."
+	}
+	return body
+}
+
+// WithLogPrefix option to prepend a tag, e.g. "[duckdns]", to every log line
+// of the client so its logs can be filtered
+func WithLogPrefix(prefix string) Option {
+	return func(c *ClientC) error {
+		c.logPrefix = prefix + " "
+		return nil
+	}
+}
+
+// domainSeparators are the characters WithDomainSeparator accepts, none of
+// them is special in a query string or valid in a duckdns domain
+const domainSeparators = ",;|:~"
+
+// WithDomainSeparator option to set the character separating the domains of
+// a multi-domain request, for a duckdns compatible server not using commas
+func WithDomainSeparator(sep string) Option {
+	return func(c *ClientC) error {
+		if len(sep) != 1 || !strings.Contains(domainSeparators, sep) {
+			return fmt.Errorf("invalid domain separator %q, expected one of %q", sep, domainSeparators)
+		}
+		if err := validateDomains(c.Config.DomainNames, sep); err != nil {
+			return fmt.Errorf("domain separator %q: %w", sep, err)
+		}
+
+		c.domainSeparator = sep
+		return nil
+	}
+}
+
+// defaultMaxDomainsPerRequest bounds the domains of a single TXT update, so
+// that many domains with a long value and verbose stay within url limits
+const defaultMaxDomainsPerRequest = 20
+
+// WithMaxDomainsPerRequest option to set how many domains a single TXT update
+// request carries, more domains are updated in several requests
+func WithMaxDomainsPerRequest(n int) Option {
+	return func(c *ClientC) error {
+		if n <= 0 {
+			return fmt.Errorf("max domains per request must be positive, got %d", n)
+		}
+
+		c.maxDomainsPerRequest = n
+		return nil
+	}
+}
+
+// WithSortedDomains option to sort and deduplicate the domains parameter of
+// the requests, so that combined requests are the same across restarts
+// whatever the configured order. Config.DomainNames is left as configured.
+func WithSortedDomains() Option {
+	return func(c *ClientC) error {
+		c.sortDomains = true
+		return nil
+	}
+}
@@ -0,0 +1,123 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+var (
+	// ErrUnreachable is returned when duckdns can't be reached at all
+	ErrUnreachable = errors.New("duckdns is unreachable")
+
+	// ErrInvalidToken is returned when duckdns rejects the token or domain
+	ErrInvalidToken = errors.New("duckdns rejected the token")
+)
+
+// Ping function to check that BaseURL answers http requests, any status
+// counts as reachable
+func (c *ClientC) Ping(ctx context.Context) error {
+	req, err := c.newRequest(ctx, http.MethodGet, c.BaseURL, "/", "/")
+	if err != nil {
+		return err
+	}
+
+	resp, err := c.request(ctx, req, nil)
+	if err != nil {
+		return fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+
+	klog.V(4).Infof("%sPing to %v answered %v", c.logPrefix, c.BaseURL, resp.Status)
+	return nil
+}
+
+// VerifyToken function to check that the token has the uuid shape of a
+// duckdns token. duckdns has no read-only api, any request carrying the token
+// is an update, so the token is never sent: a well-formed token may still be
+// revoked or belong to another account.
+func (c *ClientC) VerifyToken(ctx context.Context) error {
+	token := c.token()
+	if token == "" {
+		return fmt.Errorf("%w: %w", ErrInvalidToken, ErrEmptyToken)
+	}
+	if !looksLikeToken(token) {
+		return fmt.Errorf("%w: token does not have the shape of a duckdns token", ErrInvalidToken)
+	}
+	return nil
+}
+
+// Preflight function to check reachability then the token shape, failing fast
+// with ErrUnreachable or ErrInvalidToken, without changing any record
+func (c *ClientC) Preflight(ctx context.Context) error {
+	if err := c.Ping(ctx); err != nil {
+		return err
+	}
+	return c.VerifyToken(ctx)
+}
+
+// PreflightWith function to check a proposed configuration without creating
+// a client and return every problem found rather than the first. Only the
+// duckdns root is requested: duckdns has no read-only api, so the token is
+// checked for the uuid shape of duckdns tokens instead of being sent.
+func (c *ConfigC) PreflightWith(ctx context.Context, httpClient *http.Client) []error {
+	var errs []error
+
+	if c.Token == "" {
+		errs = append(errs, errors.New("token must be non-empty"))
+	} else if looksLikeDomain(c.Token) {
+		errs = append(errs, errors.New("token looks like a domain, token and domain names may be swapped"))
+	} else if !looksLikeToken(c.Token) {
+		errs = append(errs, errors.New("token does not have the shape of a duckdns token"))
+	}
+
+	if err := validateDomains(c.DomainNames, defaultDomainSeparator); err != nil {
+		errs = append(errs, err)
+	}
+	for _, domain := range c.DomainNames {
+		if looksLikeToken(domain) {
+			errs = append(errs, fmt.Errorf("domain %q looks like a token, token and domain names may be swapped", domain))
+		}
+	}
+
+	if httpClient == nil {
+		httpClient = http.DefaultClient
+	}
+	client := &ClientC{httpClient: httpClient,
+		BaseURL:   defaultBaseURL,
+		UserAgent: defaultUserAgent,
+		Config:    c}
+	if err := client.Ping(ctx); err != nil {
+		errs = append(errs, err)
+	}
+
+	return errs
+}
+
+// ServiceStatus function to return a human-readable health status of duckdns.
+// duckdns exposes no status endpoint, so it is derived from the answer of
+// BaseURL, a server error status reporting duckdns as degraded.
+func (c *ClientC) ServiceStatus(ctx context.Context) (string, error) {
+	req, err := c.newRequest(ctx, http.MethodGet, c.BaseURL, "/", "/")
+	if err != nil {
+		return "", err
+	}
+
+	start := time.Now()
+	resp, err := c.request(ctx, req, nil)
+	if err != nil {
+		return "unreachable", fmt.Errorf("%w: %w", ErrUnreachable, err)
+	}
+	latency := time.Since(start).Round(time.Millisecond)
+
+	switch {
+	case resp.StatusCode >= http.StatusInternalServerError:
+		return fmt.Sprintf("degraded: %v in %v", resp.Status, latency), nil
+	case resp.StatusCode == http.StatusTooManyRequests:
+		return fmt.Sprintf("rate limited: %v in %v", resp.Status, latency), nil
+	}
+	return fmt.Sprintf("operational: %v in %v", resp.Status, latency), nil
+}
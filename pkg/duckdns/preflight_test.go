@@ -0,0 +1,167 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+)
+
+// closedURL returns the url of a test server that is no longer listening
+func closedURL(t *testing.T) string {
+	t.Helper()
+
+	srv := httptest.NewServer(http.NotFoundHandler())
+	srv.Close()
+	return srv.URL
+}
+
+func TestPreflight(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusNotFound, "")))
+
+	if err := c.Preflight(context.Background()); err != nil {
+		t.Fatalf("Preflight() error = %v", err)
+	}
+	if got := log.count(); got != 1 {
+		t.Fatalf("requests = %d, want 1", got)
+	}
+	if req := log.last(t); req.URL.Path != "/" || req.URL.RawQuery != "" {
+		t.Errorf("request = %v, want the root without the token", req.URL)
+	}
+}
+
+func TestPreflightUnreachable(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+	c.BaseURL = closedURL(t)
+
+	err := c.Preflight(context.Background())
+	if !errors.Is(err, ErrUnreachable) || errors.Is(err, ErrInvalidToken) {
+		t.Fatalf("Preflight() error = %v, want %v", err, ErrUnreachable)
+	}
+}
+
+func TestPreflightBadToken(t *testing.T) {
+	config := newTestConfig()
+	config.Token = "not-a-token"
+
+	var log requestLog
+	c := newTestClient(t, config, log.wrap(respond(http.StatusOK, "OK")))
+
+	err := c.Preflight(context.Background())
+	if !errors.Is(err, ErrInvalidToken) || errors.Is(err, ErrUnreachable) {
+		t.Fatalf("Preflight() error = %v, want %v", err, ErrInvalidToken)
+	}
+	for _, req := range log.requests {
+		if req.URL.Path != "/" {
+			t.Errorf("request to %v, want no update request", req.URL)
+		}
+	}
+}
+
+// recordingClient returns an http client answering every request with an
+// empty 200 and recording its url, or failing with err when non-nil
+func recordingClient(urls *[]string, err error) *http.Client {
+	var mu sync.Mutex
+	return &http.Client{Transport: roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		mu.Lock()
+		*urls = append(*urls, req.Method+" "+req.URL.String())
+		mu.Unlock()
+		if err != nil {
+			return nil, err
+		}
+		return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Body: io.NopCloser(strings.NewReader("")), Request: req}, nil
+	})}
+}
+
+func TestPreflightWithValid(t *testing.T) {
+	var urls []string
+	errs := newTestConfig().PreflightWith(context.Background(), recordingClient(&urls, nil))
+	if len(errs) != 0 {
+		t.Errorf("PreflightWith() = %v, want no problem", errs)
+	}
+	if want := []string{"GET " + defaultBaseURL + "/"}; !reflect.DeepEqual(urls, want) {
+		t.Errorf("requests = %q, want %q", urls, want)
+	}
+}
+
+func TestPreflightWithProblems(t *testing.T) {
+	config := &ConfigC{Token: "", DomainNames: []string{"a,b", testToken}}
+
+	var urls []string
+	errs := config.PreflightWith(context.Background(), recordingClient(&urls, errors.New("connection refused")))
+
+	wants := []string{"token must be non-empty", `invalid domain "a,b"`, "looks like a token", "unreachable"}
+	if len(errs) != len(wants) {
+		t.Fatalf("PreflightWith() = %v, want %d problems", errs, len(wants))
+	}
+	for i, want := range wants {
+		if !strings.Contains(errs[i].Error(), want) {
+			t.Errorf("problem %d = %v, want %q", i, errs[i], want)
+		}
+	}
+	if !errors.Is(errs[3], ErrUnreachable) {
+		t.Errorf("problem 3 = %v, want %v", errs[3], ErrUnreachable)
+	}
+	for _, u := range urls {
+		if strings.Contains(u, "/update") {
+			t.Errorf("request %q, want no update request", u)
+		}
+	}
+}
+
+func TestPreflightWithSwappedToken(t *testing.T) {
+	config := &ConfigC{Token: "example.duckdns.org", DomainNames: []string{"example"}}
+
+	var urls []string
+	errs := config.PreflightWith(context.Background(), recordingClient(&urls, nil))
+	if len(errs) != 1 || !strings.Contains(errs[0].Error(), "swapped") {
+		t.Errorf("PreflightWith() = %v, want the swap reported", errs)
+	}
+}
+
+func TestServiceStatus(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		want   string
+	}{
+		{name: "operational", status: http.StatusOK, want: "operational: 200 OK"},
+		{name: "not found is operational", status: http.StatusNotFound, want: "operational: 404 Not Found"},
+		{name: "rate limited", status: http.StatusTooManyRequests, want: "rate limited: 429 Too Many Requests"},
+		{name: "degraded", status: http.StatusBadGateway, want: "degraded: 502 Bad Gateway"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log requestLog
+			c := newTestClient(t, newTestConfig(), log.wrap(respond(tt.status, "")))
+
+			got, err := c.ServiceStatus(context.Background())
+			if err != nil {
+				t.Fatalf("ServiceStatus() error = %v", err)
+			}
+			if !strings.HasPrefix(got, tt.want+" in ") {
+				t.Errorf("ServiceStatus() = %q, want %q with its latency", got, tt.want)
+			}
+			if req := log.last(t); req.URL.Path != "/" || req.URL.RawQuery != "" {
+				t.Errorf("request = %v, want the root without the token", req.URL)
+			}
+		})
+	}
+}
+
+func TestServiceStatusUnreachable(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+	c.BaseURL = closedURL(t)
+
+	got, err := c.ServiceStatus(context.Background())
+	if got != "unreachable" || !errors.Is(err, ErrUnreachable) {
+		t.Errorf("ServiceStatus() = %q, %v, want unreachable", got, err)
+	}
+}
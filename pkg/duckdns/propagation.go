@@ -0,0 +1,485 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"sync"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// ErrRecordNotCleared is returned when a cleared TXT record is still
+// resolvable once the verification timeout elapses
+var ErrRecordNotCleared = errors.New("txt record still present after clear")
+
+// ErrPropagationTimeout is returned when a TXT value is not visible once the
+// propagation budget elapses
+var ErrPropagationTimeout = errors.New("txt record not propagated within budget")
+
+// ErrDeadlineBeforePropagation is returned when the context deadline expires
+// before the propagation budget and before the TXT value is visible
+var ErrDeadlineBeforePropagation = errors.New("deadline exceeded before propagation")
+
+// domainTXT returns the TXT records of a domain, a missing record is reported
+// as no records rather than an error
+func (c *ClientC) domainTXT(ctx context.Context, domain string) ([]string, error) {
+	txt, err := c.lookupTXT(ctx, dnsName(domain))
+	if err != nil && !isNotFound(err) {
+		return nil, err
+	}
+	return txt, nil
+}
+
+// WithOverwriteWarning option to make TXT updates warn when they replace a
+// different non-empty value, duckdns keeps a single TXT value per domain so
+// concurrent challenges for the same domain clobber each other
+func WithOverwriteWarning(enabled bool) Option {
+	return func(c *ClientC) error {
+		c.warnOverwrite = enabled
+		return nil
+	}
+}
+
+// warnTXTOverwrite warns about every domain whose current TXT value differs
+// from the one about to be set
+func (c *ClientC) warnTXTOverwrite(ctx context.Context, domains []string, record string) {
+	for _, domain := range domains {
+		txt, err := c.domainTXT(ctx, domain)
+		if err != nil {
+			klog.V(4).Infof("%sUnable to check current txt record of %v: %v", c.logPrefix, domain, err)
+			continue
+		}
+		for _, current := range txt {
+			if current != "" && current != record {
+				klog.Warningf("%sOverwriting txt record %q of %v, duckdns keeps a single txt value per domain", c.logPrefix, current, domain)
+				break
+			}
+		}
+	}
+}
+
+// WithPreviousTXTCapture option to make TXT updates look up the value each
+// domain resolves beforehand and report it in Response.PreviousTXT, so that a
+// clean up can restore it. A value set between the lookup and the update is
+// not captured, and a cached lookup may report an older value.
+func WithPreviousTXTCapture(enabled bool) Option {
+	return func(c *ClientC) error {
+		c.capturePrevious = enabled
+		return nil
+	}
+}
+
+// previousTXT returns the current TXT value of every domain, a failed lookup
+// is logged and leaves its domain out
+func (c *ClientC) previousTXT(ctx context.Context, domains []string) map[string]string {
+	previous := make(map[string]string, len(domains))
+	for _, domain := range domains {
+		txt, err := c.domainTXT(ctx, domain)
+		if err != nil {
+			klog.V(4).Infof("%sUnable to capture current txt record of %v: %v", c.logPrefix, domain, err)
+			continue
+		}
+
+		previous[domain] = ""
+		for _, value := range txt {
+			if value != "" {
+				previous[domain] = value
+				break
+			}
+		}
+	}
+	return previous
+}
+
+// ClearRecordAndVerify function to clear the TXT record and poll until it no
+// longer resolves for any configured domain or the timeout elapses
+func (c *ClientC) ClearRecordAndVerify(ctx context.Context, pollInterval, timeout time.Duration) error {
+	if _, err := c.ClearRecord(ctx, ""); err != nil {
+		return err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	remaining := c.Config.DomainNames
+	for {
+		found, err := c.domainsWithTXT(ctx)
+		if err != nil {
+			klog.Warningf("%sUnable to verify txt record clear: %v", c.logPrefix, err)
+		} else if remaining = found; len(remaining) == 0 {
+			klog.Infof("%sVerified txt record cleared for %v", c.logPrefix, c.Config.DomainNames)
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return fmt.Errorf("%w for %v: %v", ErrRecordNotCleared, remaining, ctx.Err())
+		case <-ticker.C:
+		}
+	}
+}
+
+// domainsWithTXT returns the configured domains that still have a TXT record
+func (c *ClientC) domainsWithTXT(ctx context.Context) ([]string, error) {
+	var out []string
+	for _, domain := range c.Config.DomainNames {
+		txt, err := c.domainTXT(ctx, domain)
+		if err != nil {
+			return nil, err
+		}
+		for _, record := range txt {
+			if record != "" {
+				out = append(out, domain)
+				break
+			}
+		}
+	}
+	return out, nil
+}
+
+// defaultInitialPropagationDelay is the wait of WaitForRecord before its
+// first lookup, which would otherwise almost always miss a value just set
+const defaultInitialPropagationDelay = 2 * time.Second
+
+// WithInitialPropagationDelay option to set how long WaitForRecord waits
+// before its first lookup, so that it does not cache a negative answer of a
+// value not propagated yet. Zero checks right away.
+func WithInitialPropagationDelay(d time.Duration) Option {
+	return func(c *ClientC) error {
+		if d < 0 {
+			return fmt.Errorf("initial propagation delay must not be negative, got %v", d)
+		}
+		c.initialPropagationDelay = d
+		return nil
+	}
+}
+
+// timeAfter returns a channel receiving the time once d elapsed
+func (c *ClientC) timeAfter(d time.Duration) <-chan time.Time {
+	if c.after != nil {
+		return c.after(d)
+	}
+	return time.After(d)
+}
+
+// WaitForRecord function to poll until every configured domain resolves the
+// expected TXT value. It waits for the smaller of timeout and the context
+// deadline, the latter reported as ErrDeadlineBeforePropagation, or until ctx
+// is canceled.
+// The first lookup happens after the initial propagation delay.
+func (c *ClientC) WaitForRecord(ctx context.Context, expected string, pollInterval, timeout time.Duration) error {
+	budgetCtx, cancel := context.WithTimeout(ctx, timeout)
+	defer cancel()
+	deadline, _ := budgetCtx.Deadline()
+
+	if c.initialPropagationDelay > 0 {
+		select {
+		case <-budgetCtx.Done():
+		case <-c.timeAfter(c.initialPropagationDelay):
+		}
+	}
+
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	var pending map[string]lookupOutcome
+	for {
+		outcomes := c.classifyPropagation(budgetCtx, expected)
+		if len(outcomes) == 0 {
+			klog.Infof("%sTxt record propagated for %v", c.logPrefix, c.Config.DomainNames)
+			return nil
+		}
+		// a check cut short by the deadline keeps the outcomes of the
+		// previous one, rather than reporting its canceled lookups. The
+		// resolver may time out at the deadline before the context is done.
+		if pending == nil || (budgetCtx.Err() == nil && time.Now().Before(deadline)) {
+			pending = outcomes
+		}
+
+		select {
+		case <-budgetCtx.Done():
+			if errors.Is(ctx.Err(), context.DeadlineExceeded) {
+				return fmt.Errorf("%w for %v: %v", ErrDeadlineBeforePropagation, pending, ctx.Err())
+			}
+			if ctx.Err() != nil {
+				return fmt.Errorf("propagation check canceled for %v: %w", pending, ctx.Err())
+			}
+			return fmt.Errorf("%w of %v for %v", ErrPropagationTimeout, timeout, pending)
+		case <-ticker.C:
+		}
+	}
+}
+
+// lookupOutcome classifies why a domain does not resolve an expected TXT
+// value yet
+type lookupOutcome string
+
+const (
+	// outcomeNotPresent is a domain without TXT record, propagation has not
+	// reached the resolver yet
+	outcomeNotPresent lookupOutcome = "not present"
+	// outcomeWrongValue is a domain resolving another TXT value, either a
+	// stale cached value or an update overwritten by another client
+	outcomeWrongValue lookupOutcome = "wrong value"
+	// outcomeTransient is a failed lookup, e.g. a resolver timeout
+	outcomeTransient lookupOutcome = "lookup error"
+)
+
+// classifyPropagation returns the configured domains not resolving the
+// expected TXT value with the outcome of their lookup, logging each outcome
+func (c *ClientC) classifyPropagation(ctx context.Context, expected string) map[string]lookupOutcome {
+	pending := make(map[string]lookupOutcome)
+	for _, domain := range c.Config.DomainNames {
+		txt, err := c.domainTXT(ctx, domain)
+		switch {
+		case err != nil:
+			pending[domain] = outcomeTransient
+			klog.Warningf("%sUnable to check txt record propagation of %v: %v", c.logPrefix, domain, err)
+		case slices.Contains(txt, expected):
+		case slices.ContainsFunc(txt, func(v string) bool { return v != "" }):
+			pending[domain] = outcomeWrongValue
+			klog.Infof("%sTxt record of %v resolves %q, waiting for the new value", c.logPrefix, domain, txt)
+		default:
+			pending[domain] = outcomeNotPresent
+			klog.V(4).Infof("%sTxt record of %v not present yet", c.logPrefix, domain)
+		}
+	}
+	return pending
+}
+
+// WaitForRecords function to poll every domain of expected, a domain to TXT
+// value map, concurrently until each resolves its value or ctx is done. The
+// error names every domain not propagated with the records last seen for it.
+func (c *ClientC) WaitForRecords(ctx context.Context, expected map[string]string, pollInterval time.Duration) error {
+	var errs MultiError
+	var mu sync.Mutex
+	var wg sync.WaitGroup
+
+	for domain, value := range expected {
+		wg.Add(1)
+		go func(domain, value string) {
+			defer wg.Done()
+
+			var seen []string
+			err := c.pollUntil(ctx, pollInterval, func(ctx context.Context) (bool, error) {
+				txt, err := c.domainTXT(ctx, domain)
+				if err != nil {
+					return false, err
+				}
+				seen = txt
+				return slices.Contains(txt, value), nil
+			})
+			if err != nil {
+				mu.Lock()
+				errs.add(domain, fmt.Errorf("%w, last seen %q: %v", ErrPropagationTimeout, seen, err))
+				mu.Unlock()
+			}
+		}(domain, value)
+	}
+	wg.Wait()
+
+	if len(errs.Errors) == 0 {
+		klog.Infof("%sTxt records propagated for %d domains", c.logPrefix, len(expected))
+	}
+	return errs.errOrNil()
+}
+
+// WaitForRecordOnAuthoritative function to poll the duckdns authoritative
+// nameservers directly until every one of them answers the expected TXT value
+// for every configured domain, or ctx is done. Unlike GetRecordsAuthoritative
+// it fails when the nameservers can't be discovered.
+func (c *ClientC) WaitForRecordOnAuthoritative(ctx context.Context, expected string, pollInterval time.Duration) error {
+	servers, err := c.authoritativeServers(ctx)
+	if err != nil {
+		return fmt.Errorf("unable to discover duckdns nameservers: %w", err)
+	}
+
+	var pending []string
+	err = c.pollUntil(ctx, pollInterval, func(ctx context.Context) (bool, error) {
+		// an interrupted check keeps the pending list of the previous one
+		var missing []string
+		for _, server := range servers {
+			for _, domain := range c.Config.DomainNames {
+				txt, err := c.lookupTXTAt(ctx, server, dnsName(domain))
+				if err != nil && !isNotFound(err) {
+					return false, fmt.Errorf("%s: %w", server, err)
+				}
+				if !slices.Contains(txt, expected) {
+					missing = append(missing, domain+"@"+server)
+				}
+			}
+		}
+		pending = missing
+		return len(pending) == 0, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w on authoritative nameservers, pending %v: %v", ErrPropagationTimeout, pending, err)
+	}
+
+	klog.Infof("%sTxt record propagated for %v on nameservers %v", c.logPrefix, c.Config.DomainNames, servers)
+	return nil
+}
+
+// PropagationRatio function to return the fraction of the given nameservers
+// currently answering the expected TXT value for every configured domain. A
+// nameserver failing to answer counts as not propagated, an error is only
+// returned when none answered.
+func (c *ClientC) PropagationRatio(ctx context.Context, expected string, resolvers []string) (float64, error) {
+	if len(resolvers) == 0 {
+		return 0, errors.New("at least one resolver is required")
+	}
+
+	var propagated int
+	var mu sync.Mutex
+
+	err := forEachDomain(ctx, resolvers, func(ctx context.Context, server string) error {
+		ok := true
+		for _, domain := range c.Config.DomainNames {
+			txt, err := c.lookupTXTAt(ctx, server, dnsName(domain))
+			if err != nil && !isNotFound(err) {
+				return err
+			}
+			if !slices.Contains(txt, expected) {
+				ok = false
+				break
+			}
+		}
+
+		if ok {
+			mu.Lock()
+			propagated++
+			mu.Unlock()
+		}
+		return nil
+	})
+	var errs *MultiError
+	if errors.As(err, &errs) && len(errs.Errors) == len(resolvers) {
+		return 0, err
+	}
+	if err != nil {
+		klog.V(4).Infof("%sUnable to check txt record propagation on some resolvers: %v", c.logPrefix, err)
+	}
+
+	return float64(propagated) / float64(len(resolvers)), nil
+}
+
+// domainsMissing returns the configured domains not resolving the expected
+// TXT value
+func (c *ClientC) domainsMissing(ctx context.Context, expected string) ([]string, error) {
+	var out []string
+	for _, domain := range c.Config.DomainNames {
+		txt, err := c.domainTXT(ctx, domain)
+		if err != nil {
+			return nil, err
+		}
+		if !slices.Contains(txt, expected) {
+			out = append(out, domain)
+		}
+	}
+	return out, nil
+}
+
+// PresentChallenge function to update the TXT record and wait for it to
+// propagate, bounded by the smaller of timeout and the context deadline
+func (c *ClientC) PresentChallenge(ctx context.Context, value string, pollInterval, timeout time.Duration) error {
+	if _, err := c.UpdateRecord(ctx, value); err != nil {
+		return err
+	}
+
+	err := c.WaitForRecord(ctx, value, pollInterval, timeout)
+	for reissue := 1; c.selfHealing && reissue <= maxSelfHealReissues && errors.Is(err, ErrPropagationTimeout); reissue++ {
+		klog.Warningf("%sTxt record of %v not visible after %v, re-issuing update (%d/%d)", c.logPrefix, c.Config.DomainNames, timeout, reissue, maxSelfHealReissues)
+		if _, err := c.UpdateRecord(ctx, value); err != nil {
+			return err
+		}
+		err = c.WaitForRecord(ctx, value, pollInterval, timeout)
+	}
+	return err
+}
+
+// maxSelfHealReissues bounds the update re-issues of a self-healing update
+const maxSelfHealReissues = 1
+
+// WithSelfHealingUpdate option to make PresentChallenge re-issue the update
+// once when the value is still not visible after the propagation budget
+func WithSelfHealingUpdate() Option {
+	return func(c *ClientC) error {
+		c.selfHealing = true
+		return nil
+	}
+}
+
+var (
+	// ErrOldRecordPersisted is returned by ReplaceRecord when the old TXT
+	// value still resolves once the context is done
+	ErrOldRecordPersisted = errors.New("old txt value still resolvable")
+
+	// ErrNewRecordNotVisible is returned by ReplaceRecord when the new TXT
+	// value does not resolve once the context is done
+	ErrNewRecordNotVisible = errors.New("new txt value not visible")
+)
+
+// pollUntil calls check every pollInterval until it reports done or ctx is
+// done, lookup errors are logged and polled again
+func (c *ClientC) pollUntil(ctx context.Context, pollInterval time.Duration, check func(ctx context.Context) (bool, error)) error {
+	ticker := time.NewTicker(pollInterval)
+	defer ticker.Stop()
+
+	for {
+		done, err := check(ctx)
+		if err != nil {
+			klog.Warningf("%sUnable to check txt record: %v", c.logPrefix, err)
+		} else if done {
+			return nil
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// ReplaceRecord function to clear the TXT record, wait until oldValue no
+// longer resolves, then set newValue and wait until it resolves, for ACME
+// flows that must not see both values. The whole sequence is bounded by ctx.
+func (c *ClientC) ReplaceRecord(ctx context.Context, oldValue, newValue string, pollInterval time.Duration) error {
+	if _, err := c.ClearRecord(ctx, oldValue); err != nil {
+		return err
+	}
+
+	err := c.pollUntil(ctx, pollInterval, func(ctx context.Context) (bool, error) {
+		for _, domain := range c.Config.DomainNames {
+			txt, err := c.domainTXT(ctx, domain)
+			if err != nil || slices.Contains(txt, oldValue) {
+				return false, err
+			}
+		}
+		return true, nil
+	})
+	if err != nil {
+		return fmt.Errorf("%w for %v: %v", ErrOldRecordPersisted, c.Config.DomainNames, err)
+	}
+
+	if _, err := c.UpdateRecord(ctx, newValue); err != nil {
+		return err
+	}
+
+	err = c.pollUntil(ctx, pollInterval, func(ctx context.Context) (bool, error) {
+		pending, err := c.domainsMissing(ctx, newValue)
+		return err == nil && len(pending) == 0, err
+	})
+	if err != nil {
+		return fmt.Errorf("%w for %v: %v", ErrNewRecordNotVisible, c.Config.DomainNames, err)
+	}
+
+	klog.Infof("%sReplaced txt record of %v", c.logPrefix, c.Config.DomainNames)
+	return nil
+}
@@ -0,0 +1,664 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"maps"
+	"math"
+	"net"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/miekg/dns"
+)
+
+func TestClearRecordAndVerify(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("example.duckdns.org", "old")
+
+	c := newTestClient(t, newTestConfig(), func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("clear") == "true" {
+			zone.setTXT("example.duckdns.org")
+		}
+		w.Write([]byte("OK"))
+	}, WithResolver(zone.resolver()))
+
+	if err := c.ClearRecordAndVerify(context.Background(), 10*time.Millisecond, time.Second); err != nil {
+		t.Fatalf("ClearRecordAndVerify() error = %v", err)
+	}
+}
+
+func TestClearRecordAndVerifyNeverClears(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("example.duckdns.org", "old")
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithResolver(zone.resolver()))
+
+	err := c.ClearRecordAndVerify(context.Background(), 10*time.Millisecond, 100*time.Millisecond)
+	if !errors.Is(err, ErrRecordNotCleared) {
+		t.Fatalf("ClearRecordAndVerify() error = %v, want %v", err, ErrRecordNotCleared)
+	}
+}
+
+func TestWaitForRecordContextDeadline(t *testing.T) {
+	zone := newFakeZone(t)
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithResolver(zone.resolver()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := c.WaitForRecord(ctx, "value", 10*time.Millisecond, 10*time.Second)
+	if !errors.Is(err, ErrDeadlineBeforePropagation) {
+		t.Fatalf("WaitForRecord() error = %v, want %v", err, ErrDeadlineBeforePropagation)
+	}
+	// the context deadline also cuts the initial propagation delay short
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("WaitForRecord() took %v, want it to return at the context deadline", elapsed)
+	}
+}
+
+func TestWaitForRecordCanceled(t *testing.T) {
+	zone := newFakeZone(t)
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"),
+		WithResolver(zone.resolver()), WithInitialPropagationDelay(0))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	err := c.WaitForRecord(ctx, "value", 10*time.Millisecond, 10*time.Second)
+	if !errors.Is(err, context.Canceled) {
+		t.Fatalf("WaitForRecord() error = %v, want %v", err, context.Canceled)
+	}
+	if errors.Is(err, ErrDeadlineBeforePropagation) || errors.Is(err, ErrPropagationTimeout) {
+		t.Errorf("WaitForRecord() error = %v, want a cancellation rather than a deadline", err)
+	}
+}
+
+func TestWaitForRecordBudget(t *testing.T) {
+	zone := newFakeZone(t)
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"),
+		WithResolver(zone.resolver()), WithInitialPropagationDelay(0))
+
+	err := c.WaitForRecord(context.Background(), "value", 10*time.Millisecond, 100*time.Millisecond)
+	if !errors.Is(err, ErrPropagationTimeout) || errors.Is(err, ErrDeadlineBeforePropagation) {
+		t.Fatalf("WaitForRecord() error = %v, want %v", err, ErrPropagationTimeout)
+	}
+}
+
+func TestPresentChallengeContextDeadline(t *testing.T) {
+	zone := newFakeZone(t)
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")), WithResolver(zone.resolver()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	err := c.PresentChallenge(ctx, "value", 10*time.Millisecond, 10*time.Second)
+	if !errors.Is(err, ErrDeadlineBeforePropagation) {
+		t.Fatalf("PresentChallenge() error = %v, want %v", err, ErrDeadlineBeforePropagation)
+	}
+	if elapsed := time.Since(start); elapsed > time.Second {
+		t.Errorf("PresentChallenge() took %v, want it to return at the context deadline", elapsed)
+	}
+	if got := log.count(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestOverwriteWarning(t *testing.T) {
+	tests := []struct {
+		name     string
+		enabled  bool
+		existing []string
+		want     int
+	}{
+		{name: "prior value", enabled: true, existing: []string{"prior"}, want: 1},
+		{name: "same value", enabled: true, existing: []string{"value"}},
+		{name: "no record", enabled: true},
+		{name: "disabled", existing: []string{"prior"}},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t, 0)
+			zone := newFakeZone(t)
+			if tt.existing != nil {
+				zone.setTXT("example.duckdns.org", tt.existing...)
+			}
+			c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"),
+				WithResolver(zone.resolver()), WithOverwriteWarning(tt.enabled))
+
+			if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+				t.Fatalf("UpdateRecord() error = %v", err)
+			}
+			if got := logs.lines("Overwriting txt record"); len(got) != tt.want {
+				t.Errorf("overwrite warnings = %q, want %d", got, tt.want)
+			}
+		})
+	}
+}
+
+// applyToZone returns a handler applying the clear and txt updates it
+// receives to the TXT record of name in zone
+func applyToZone(zone *fakeZone, name string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		if q.Get("clear") == "true" {
+			zone.setTXT(name)
+		} else {
+			zone.setTXT(name, q.Get("txt"))
+		}
+		w.Write([]byte("OK"))
+	}
+}
+
+func TestReplaceRecord(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("example.duckdns.org", "old")
+
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(applyToZone(zone, "example.duckdns.org")), WithResolver(zone.resolver()))
+
+	if err := c.ReplaceRecord(context.Background(), "old", "new", 10*time.Millisecond); err != nil {
+		t.Fatalf("ReplaceRecord() error = %v", err)
+	}
+	if got := log.count(); got != 2 {
+		t.Fatalf("requests = %d, want 2", got)
+	}
+	if q := log.requests[0].URL.Query(); q.Get("clear") != "true" {
+		t.Errorf("first query = %v, want a clear", q)
+	}
+	if q := log.requests[1].URL.Query(); q.Get("txt") != "new" || q.Has("clear") {
+		t.Errorf("second query = %v, want an update to new", q)
+	}
+}
+
+func TestReplaceRecordPhaseTimeouts(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler func(zone *fakeZone) http.HandlerFunc
+		want    error
+	}{
+		{
+			name:    "old value persists",
+			handler: func(*fakeZone) http.HandlerFunc { return respond(http.StatusOK, "OK") },
+			want:    ErrOldRecordPersisted,
+		},
+		{
+			name: "new value never visible",
+			handler: func(zone *fakeZone) http.HandlerFunc {
+				return func(w http.ResponseWriter, r *http.Request) {
+					zone.setTXT("example.duckdns.org")
+					w.Write([]byte("OK"))
+				}
+			},
+			want: ErrNewRecordNotVisible,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zone := newFakeZone(t)
+			zone.setTXT("example.duckdns.org", "old")
+			c := newTestClient(t, newTestConfig(), tt.handler(zone), WithResolver(zone.resolver()))
+
+			ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+			defer cancel()
+
+			err := c.ReplaceRecord(ctx, "old", "new", 10*time.Millisecond)
+			if !errors.Is(err, tt.want) {
+				t.Fatalf("ReplaceRecord() error = %v, want %v", err, tt.want)
+			}
+		})
+	}
+}
+
+// sticksAfter returns a handler publishing the txt value of the requests in
+// zone only from the n-th request on, as an update lost by duckdns would
+func sticksAfter(zone *fakeZone, n int) http.HandlerFunc {
+	var mu sync.Mutex
+	var count int
+	return func(w http.ResponseWriter, r *http.Request) {
+		mu.Lock()
+		count++
+		if count >= n {
+			zone.setTXT("example.duckdns.org", r.URL.Query().Get("txt"))
+		}
+		mu.Unlock()
+		w.Write([]byte("OK"))
+	}
+}
+
+func TestPresentChallengeSelfHealing(t *testing.T) {
+	tests := []struct {
+		name         string
+		selfHealing  bool
+		sticksAfter  int
+		wantErr      error
+		wantRequests int
+	}{
+		{name: "sticks after a re-issue", selfHealing: true, sticksAfter: 2, wantRequests: 2},
+		{name: "never sticks", selfHealing: true, sticksAfter: 10, wantErr: ErrPropagationTimeout, wantRequests: 2},
+		{name: "disabled", sticksAfter: 2, wantErr: ErrPropagationTimeout, wantRequests: 1},
+		{name: "sticks right away", selfHealing: true, sticksAfter: 1, wantRequests: 1},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zone := newFakeZone(t)
+			opts := []Option{WithResolver(zone.resolver()), WithInitialPropagationDelay(0)}
+			if tt.selfHealing {
+				opts = append(opts, WithSelfHealingUpdate())
+			}
+			var log requestLog
+			c := newTestClient(t, newTestConfig(), log.wrap(sticksAfter(zone, tt.sticksAfter)), opts...)
+
+			err := c.PresentChallenge(context.Background(), "value", 10*time.Millisecond, 100*time.Millisecond)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("PresentChallenge() error = %v, want %v", err, tt.wantErr)
+			}
+			if got := log.count(); got != tt.wantRequests {
+				t.Errorf("requests = %d, want %d", got, tt.wantRequests)
+			}
+		})
+	}
+}
+
+func TestWaitForRecordsSlowDomain(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("fast.duckdns.org", "one")
+	zone.setTXT("slow.duckdns.org", "old")
+	time.AfterFunc(50*time.Millisecond, func() { zone.setTXT("slow.duckdns.org", "two") })
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithResolver(zone.resolver()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.WaitForRecords(ctx, map[string]string{"fast": "one", "slow": "two"}, 10*time.Millisecond); err != nil {
+		t.Fatalf("WaitForRecords() error = %v", err)
+	}
+	if got := zone.count(dns.TypeTXT, "slow.duckdns.org"); got < 2 {
+		t.Errorf("lookups of slow = %d, want it polled until propagated", got)
+	}
+	if got := zone.count(dns.TypeTXT, "fast.duckdns.org"); got != 1 {
+		t.Errorf("lookups of fast = %d, want 1", got)
+	}
+}
+
+func TestWaitForRecordsTimeout(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("fast.duckdns.org", "one")
+	zone.setTXT("stuck.duckdns.org", "old")
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithResolver(zone.resolver()))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+	err := c.WaitForRecords(ctx, map[string]string{"fast": "one", "stuck": "two"}, 10*time.Millisecond)
+
+	var multi *MultiError
+	if !errors.As(err, &multi) || len(multi.Errors) != 1 || multi.Errors[0].Domain != "stuck" {
+		t.Fatalf("WaitForRecords() error = %v, want a failure of stuck only", err)
+	}
+	if !errors.Is(err, ErrPropagationTimeout) || !strings.Contains(err.Error(), `"old"`) {
+		t.Errorf("WaitForRecords() error = %v, want a timeout naming the last seen value", err)
+	}
+}
+
+func TestWaitForRecordOnAuthoritative(t *testing.T) {
+	first := newFakeZone(t)
+	first.setTXT("example.duckdns.org", "value")
+	lagging := newFakeZone(t)
+	lagging.setTXT("example.duckdns.org", "old")
+	time.AfterFunc(50*time.Millisecond, func() { lagging.setTXT("example.duckdns.org", "value") })
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"),
+		WithAuthoritativeServers([]string{first.addr, lagging.addr}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	if err := c.WaitForRecordOnAuthoritative(ctx, "value", 10*time.Millisecond); err != nil {
+		t.Fatalf("WaitForRecordOnAuthoritative() error = %v", err)
+	}
+	if got := lagging.count(dns.TypeTXT, "example.duckdns.org"); got < 2 {
+		t.Errorf("lookups on the lagging nameserver = %d, want polling until it answers", got)
+	}
+}
+
+func TestWaitForRecordOnAuthoritativeDiscovery(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setNS("duckdns.org", "ns1.duckdns.org.", "ns2.duckdns.org.")
+	zone.setTXT("example.duckdns.org", "value")
+
+	// every lookup, the nameserver ones included, reaches the fake zone
+	var mu sync.Mutex
+	var dialed []string
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"),
+		WithResolverDialer(func(ctx context.Context, network, address string) (net.Conn, error) {
+			mu.Lock()
+			dialed = append(dialed, address)
+			mu.Unlock()
+			var d net.Dialer
+			return d.DialContext(ctx, network, zone.addr)
+		}))
+
+	if err := c.WaitForRecordOnAuthoritative(context.Background(), "value", 10*time.Millisecond); err != nil {
+		t.Fatalf("WaitForRecordOnAuthoritative() error = %v", err)
+	}
+	if got := zone.count(dns.TypeTXT, "example.duckdns.org"); got != 2 {
+		t.Errorf("TXT lookups = %d, want one per nameserver", got)
+	}
+
+	mu.Lock()
+	defer mu.Unlock()
+	for _, want := range []string{"ns1.duckdns.org:53", "ns2.duckdns.org:53"} {
+		if !slices.Contains(dialed, want) {
+			t.Errorf("dialed %v, want %s among them", dialed, want)
+		}
+	}
+}
+
+func TestWaitForRecordOnAuthoritativeDiscoveryFailure(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setRcode("duckdns.org", dns.RcodeRefused)
+	zone.setTXT("example.duckdns.org", "value")
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithResolver(zone.resolver()))
+
+	err := c.WaitForRecordOnAuthoritative(context.Background(), "value", 10*time.Millisecond)
+	if err == nil || !strings.Contains(err.Error(), "unable to discover duckdns nameservers") {
+		t.Fatalf("WaitForRecordOnAuthoritative() error = %v, want a discovery failure", err)
+	}
+	// no fall back to the recursive resolver
+	if got := zone.count(dns.TypeTXT, "example.duckdns.org"); got != 0 {
+		t.Errorf("TXT lookups = %d, want 0", got)
+	}
+}
+
+func TestWaitForRecordOnAuthoritativeTimeout(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("example.duckdns.org", "old")
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithAuthoritativeServers([]string{zone.addr}))
+
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+	err := c.WaitForRecordOnAuthoritative(ctx, "value", 10*time.Millisecond)
+	if !errors.Is(err, ErrPropagationTimeout) || !strings.Contains(err.Error(), "example@"+zone.addr) {
+		t.Fatalf("WaitForRecordOnAuthoritative() error = %v, want a timeout naming the pending nameserver", err)
+	}
+}
+
+func TestWaitForRecordOnAuthoritativeConcurrency(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("first.duckdns.org", "value")
+	zone.setTXT("second.duckdns.org", "value")
+	zone.setDelay(20 * time.Millisecond)
+
+	c := newTestClient(t, newTestConfig("first", "second"), respond(http.StatusOK, "OK"),
+		WithAuthoritativeServers([]string{zone.addr}), WithMaxDNSConcurrency(1))
+
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			if err := c.WaitForRecordOnAuthoritative(context.Background(), "value", 10*time.Millisecond); err != nil {
+				t.Errorf("WaitForRecordOnAuthoritative() error = %v", err)
+			}
+		}()
+	}
+	wg.Wait()
+
+	if peak := zone.peakInflight(); peak != 1 {
+		t.Errorf("peak in-flight lookups = %d, want 1", peak)
+	}
+}
+
+func TestPreviousTXTCapture(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("first.duckdns.org", "old")
+	zone.setRcode("broken.duckdns.org", dns.RcodeRefused)
+
+	// the update lands in the zone, the captured value must predate it
+	c := newTestClient(t, newTestConfig("first", "empty", "broken"), applyToZone(zone, "first.duckdns.org"),
+		WithResolver(zone.resolver()), WithPreviousTXTCapture(true))
+
+	resp, err := c.UpdateRecord(context.Background(), "new")
+	if err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	// a domain failing to resolve has no entry, unlike one without a record
+	want := map[string]string{"first": "old", "empty": ""}
+	if !maps.Equal(resp.PreviousTXT, want) {
+		t.Errorf("PreviousTXT = %q, want %q", resp.PreviousTXT, want)
+	}
+}
+
+func TestPreviousTXTCaptureDisabled(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("example.duckdns.org", "old")
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithResolver(zone.resolver()))
+
+	resp, err := c.UpdateRecord(context.Background(), "new")
+	if err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if resp.PreviousTXT != nil {
+		t.Errorf("PreviousTXT = %q, want nil", resp.PreviousTXT)
+	}
+	if got := zone.count(dns.TypeTXT, "example.duckdns.org"); got != 0 {
+		t.Errorf("TXT lookups = %d, want 0", got)
+	}
+}
+
+func TestPropagationRatio(t *testing.T) {
+	tests := []struct {
+		name string
+		set  func(zones []*fakeZone)
+		want float64
+	}{
+		{
+			name: "one of three",
+			set: func(zones []*fakeZone) {
+				zones[0].setTXT("example.duckdns.org", "value")
+				zones[1].setTXT("example.duckdns.org", "old")
+			},
+			want: 1.0 / 3,
+		},
+		{
+			name: "failing resolver counts as not propagated",
+			set: func(zones []*fakeZone) {
+				zones[0].setTXT("example.duckdns.org", "value")
+				zones[1].setTXT("example.duckdns.org", "value")
+				zones[2].setRcode("example.duckdns.org", dns.RcodeServerFailure)
+			},
+			want: 2.0 / 3,
+		},
+		{
+			name: "all",
+			set: func(zones []*fakeZone) {
+				for _, zone := range zones {
+					zone.setTXT("example.duckdns.org", "value")
+				}
+			},
+			want: 1,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			zones := []*fakeZone{newFakeZone(t), newFakeZone(t), newFakeZone(t)}
+			tt.set(zones)
+			c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+
+			got, err := c.PropagationRatio(context.Background(), "value", []string{zones[0].addr, zones[1].addr, zones[2].addr})
+			if err != nil {
+				t.Fatalf("PropagationRatio() error = %v", err)
+			}
+			if math.Abs(got-tt.want) > 1e-9 {
+				t.Errorf("PropagationRatio() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestPropagationRatioNoAnswer(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setRcode("example.duckdns.org", dns.RcodeServerFailure)
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+
+	if _, err := c.PropagationRatio(context.Background(), "value", []string{zone.addr}); err == nil {
+		t.Error("PropagationRatio() error = nil when no resolver answered, want an error")
+	}
+	if _, err := c.PropagationRatio(context.Background(), "value", nil); err == nil {
+		t.Error("PropagationRatio() error = nil without resolvers, want an error")
+	}
+}
+
+func TestClassifyPropagation(t *testing.T) {
+	logs := captureLogs(t, 4)
+
+	zone := newFakeZone(t)
+	zone.setTXT("done.duckdns.org", "value")
+	zone.setTXT("stale.duckdns.org", "old")
+	zone.setTXT("empty.duckdns.org", "")
+	zone.setRcode("broken.duckdns.org", dns.RcodeRefused)
+
+	c := newTestClient(t, newTestConfig("done", "stale", "missing", "empty", "broken"), respond(http.StatusOK, "OK"),
+		WithResolver(zone.resolver()))
+
+	got := c.classifyPropagation(context.Background(), "value")
+	want := map[string]lookupOutcome{
+		"stale":   outcomeWrongValue,
+		"missing": outcomeNotPresent,
+		"empty":   outcomeNotPresent,
+		"broken":  outcomeTransient,
+	}
+	if !maps.Equal(got, want) {
+		t.Errorf("classifyPropagation() = %v, want %v", got, want)
+	}
+
+	tests := []struct {
+		message  string
+		severity string
+	}{
+		{message: "Unable to check txt record propagation of broken", severity: "W"},
+		{message: `Txt record of stale resolves ["old"]`, severity: "I"},
+		{message: "Txt record of missing not present yet", severity: "I"},
+	}
+	for _, tt := range tests {
+		if lines := logs.lines(tt.message); len(lines) != 1 || !strings.HasPrefix(lines[0], tt.severity) {
+			t.Errorf("logged %q, want a single %s line holding %q", lines, tt.severity, tt.message)
+		}
+	}
+}
+
+func TestWaitForRecordOutcomes(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("stale.duckdns.org", "old")
+	zone.setRcode("broken.duckdns.org", dns.RcodeRefused)
+
+	c := newTestClient(t, newTestConfig("stale", "missing", "broken"), respond(http.StatusOK, "OK"),
+		WithResolver(zone.resolver()), WithInitialPropagationDelay(0))
+
+	err := c.WaitForRecord(context.Background(), "value", 10*time.Millisecond, 50*time.Millisecond)
+	if !errors.Is(err, ErrPropagationTimeout) {
+		t.Fatalf("WaitForRecord() error = %v, want %v", err, ErrPropagationTimeout)
+	}
+	for _, want := range []string{"stale:wrong value", "missing:not present", "broken:lookup error"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("WaitForRecord() error = %v, want it to hold %q", err, want)
+		}
+	}
+}
+
+func TestWaitForRecordTransientKeepsPolling(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setRcode("example.duckdns.org", dns.RcodeServerFailure)
+	time.AfterFunc(30*time.Millisecond, func() {
+		zone.setRcode("example.duckdns.org", dns.RcodeSuccess)
+		zone.setTXT("example.duckdns.org", "value")
+	})
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"),
+		WithResolver(zone.resolver()), WithInitialPropagationDelay(0))
+
+	if err := c.WaitForRecord(context.Background(), "value", 10*time.Millisecond, 5*time.Second); err != nil {
+		t.Fatalf("WaitForRecord() error = %v, want the transient failures outlived", err)
+	}
+}
+
+// fakeAfter is a clock whose timers only fire when told to
+type fakeAfter struct {
+	waits chan time.Duration
+	fire  chan time.Time
+}
+
+func newFakeAfter() *fakeAfter {
+	return &fakeAfter{waits: make(chan time.Duration, 1), fire: make(chan time.Time)}
+}
+
+// after records the wait for d and returns the channel fired by the test
+func (f *fakeAfter) after(d time.Duration) <-chan time.Time {
+	f.waits <- d
+	return f.fire
+}
+
+func TestWaitForRecordInitialDelay(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("example.duckdns.org", "value")
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithResolver(zone.resolver()))
+	clock := newFakeAfter()
+	c.after = clock.after
+
+	errc := make(chan error, 1)
+	go func() { errc <- c.WaitForRecord(context.Background(), "value", 10*time.Millisecond, 5*time.Second) }()
+
+	if d := <-clock.waits; d != defaultInitialPropagationDelay {
+		t.Errorf("initial delay = %v, want %v", d, defaultInitialPropagationDelay)
+	}
+	time.Sleep(50 * time.Millisecond)
+	if got := zone.count(dns.TypeTXT, "example.duckdns.org"); got != 0 {
+		t.Fatalf("TXT lookups before the initial delay elapsed = %d, want 0", got)
+	}
+
+	clock.fire <- time.Now()
+	if err := <-errc; err != nil {
+		t.Fatalf("WaitForRecord() error = %v", err)
+	}
+	if got := zone.count(dns.TypeTXT, "example.duckdns.org"); got != 1 {
+		t.Errorf("TXT lookups = %d, want 1", got)
+	}
+}
+
+func TestWaitForRecordInitialDelayDisabled(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("example.duckdns.org", "value")
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"),
+		WithResolver(zone.resolver()), WithInitialPropagationDelay(0))
+	clock := newFakeAfter()
+	c.after = clock.after
+
+	if err := c.WaitForRecord(context.Background(), "value", 10*time.Millisecond, 5*time.Second); err != nil {
+		t.Fatalf("WaitForRecord() error = %v", err)
+	}
+	if len(clock.waits) != 0 {
+		t.Error("WaitForRecord() waited without an initial delay")
+	}
+}
+
+func TestWithInitialPropagationDelayNegative(t *testing.T) {
+	if err := WithInitialPropagationDelay(-time.Second)(&ClientC{}); err == nil {
+		t.Error("WithInitialPropagationDelay(-1s) error = nil, want an error")
+	}
+}
@@ -0,0 +1,92 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// maxEchoBodySize bounds how much of an ip-echo response is read, a plain
+// address is far smaller
+const maxEchoBodySize = 256
+
+var defaultIPEchoEndpoints = []string{
+	"https://api.ipify.org",
+	"https://ipv4.icanhazip.com",
+	"https://checkip.amazonaws.com",
+}
+
+// WithIPEchoEndpoints option to set the ordered list of ip-echo services used
+// by DetectPublicIP, the next endpoint is tried when one fails
+func WithIPEchoEndpoints(endpoints []string) Option {
+	return func(c *ClientC) error {
+		if len(endpoints) == 0 {
+			return errors.New("at least one ip echo endpoint is required")
+		}
+		c.ipEchoEndpoints = append([]string(nil), endpoints...)
+		return nil
+	}
+}
+
+// DetectPublicIP function to detect the public IP address of the host by
+// querying the configured ip-echo endpoints in order
+func (c *ClientC) DetectPublicIP(ctx context.Context) (string, error) {
+	endpoints := c.ipEchoEndpoints
+	if len(endpoints) == 0 {
+		endpoints = defaultIPEchoEndpoints
+	}
+
+	var errs []error
+	for _, endpoint := range endpoints {
+		ip, err := c.queryIPEcho(ctx, endpoint)
+		if err == nil {
+			klog.V(4).Infof("%sDetected public ip %v from %v", c.logPrefix, ip, endpoint)
+			return ip, nil
+		}
+
+		klog.Warningf("%sUnable to detect public ip from %v: %v", c.logPrefix, endpoint, err)
+		errs = append(errs, fmt.Errorf("%s: %w", endpoint, err))
+
+		if ctx.Err() != nil {
+			break
+		}
+	}
+
+	return "", fmt.Errorf("unable to detect public ip: %w", errors.Join(errs...))
+}
+
+func (c *ClientC) queryIPEcho(ctx context.Context, endpoint string) (string, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, endpoint, nil)
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("User-Agent", c.UserAgent)
+
+	resp, err := c.httpClient.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("unexpected status %v", resp.Status)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxEchoBodySize))
+	if err != nil {
+		return "", err
+	}
+
+	ip := net.ParseIP(strings.TrimSpace(string(body)))
+	if ip == nil {
+		return "", fmt.Errorf("unparseable address %q", strings.TrimSpace(string(body)))
+	}
+
+	return ip.String(), nil
+}
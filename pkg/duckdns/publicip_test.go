@@ -0,0 +1,59 @@
+package duckdns
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newEchoServer returns the url of a test ip-echo server answering with
+// status and body
+func newEchoServer(t *testing.T, status int, body string) string {
+	t.Helper()
+
+	srv := httptest.NewServer(respond(status, body))
+	t.Cleanup(srv.Close)
+	return srv.URL
+}
+
+func TestDetectPublicIPFailover(t *testing.T) {
+	failing := newEchoServer(t, http.StatusServiceUnavailable, "")
+	garbled := newEchoServer(t, http.StatusOK, "<html>not an address</html>")
+	working := newEchoServer(t, http.StatusOK, "203.0.113.7\n")
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithIPEchoEndpoints([]string{failing, garbled, working}))
+
+	ip, err := c.DetectPublicIP(context.Background())
+	if err != nil {
+		t.Fatalf("DetectPublicIP() error = %v", err)
+	}
+	if ip != "203.0.113.7" {
+		t.Errorf("DetectPublicIP() = %q, want 203.0.113.7", ip)
+	}
+}
+
+func TestDetectPublicIPAllFailing(t *testing.T) {
+	failing := newEchoServer(t, http.StatusServiceUnavailable, "")
+	garbled := newEchoServer(t, http.StatusOK, "garbage")
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithIPEchoEndpoints([]string{failing, garbled}))
+
+	_, err := c.DetectPublicIP(context.Background())
+	if err == nil {
+		t.Fatal("DetectPublicIP() error = nil, want an error")
+	}
+	for _, want := range []string{failing, "503", garbled, "unparseable address"} {
+		if !strings.Contains(err.Error(), want) {
+			t.Errorf("DetectPublicIP() error = %q, want it to mention %q", err, want)
+		}
+	}
+}
+
+func TestWithIPEchoEndpointsEmpty(t *testing.T) {
+	c := &ClientC{}
+	if err := WithIPEchoEndpoints(nil)(c); err == nil {
+		t.Error("WithIPEchoEndpoints(nil) error = nil, want an error")
+	}
+}
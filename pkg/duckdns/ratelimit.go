@@ -0,0 +1,58 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"sync"
+	"time"
+)
+
+// rateLimiter spaces the requests of a client by a least interval
+type rateLimiter struct {
+	interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// WithRateLimit option to space the requests sent to duckdns, retries
+// included, by at least interval. Requests are not limited by default.
+func WithRateLimit(interval time.Duration) Option {
+	return func(c *ClientC) error {
+		if interval <= 0 {
+			return errors.New("rate limit interval must be positive")
+		}
+		c.limiter = &rateLimiter{interval: interval}
+		return nil
+	}
+}
+
+// wait blocks until the next request may be sent or ctx is done, a nil
+// limiter never blocks
+func (l *rateLimiter) wait(ctx context.Context) error {
+	if l == nil {
+		return nil
+	}
+
+	l.mu.Lock()
+	now := time.Now()
+	at := l.next
+	if at.Before(now) {
+		at = now
+	}
+	l.next = at.Add(l.interval)
+	l.mu.Unlock()
+
+	delay := at.Sub(now)
+	if delay <= 0 {
+		return nil
+	}
+	timer := time.NewTimer(delay)
+	defer timer.Stop()
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-timer.C:
+		return nil
+	}
+}
@@ -0,0 +1,53 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestRateLimitSpacesRequests(t *testing.T) {
+	const interval = 50 * time.Millisecond
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")), WithRateLimit(interval))
+
+	start := time.Now()
+	for i := 0; i < 3; i++ {
+		if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+			t.Fatalf("UpdateRecord() error = %v", err)
+		}
+	}
+	if elapsed := time.Since(start); elapsed < 2*interval {
+		t.Errorf("3 requests took %v, want at least %v", elapsed, 2*interval)
+	}
+	if got := log.count(); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+}
+
+func TestRateLimitCanceled(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")), WithRateLimit(time.Hour))
+	c.Retry.MaxAttempts = 1
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	if _, err := c.UpdateRecord(ctx, "value"); !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("UpdateRecord() error = %v, want %v", err, context.DeadlineExceeded)
+	}
+	if got := log.count(); got != 1 {
+		t.Errorf("requests = %d, want the limited one not sent", got)
+	}
+}
+
+func TestWithRateLimitInvalid(t *testing.T) {
+	if err := WithRateLimit(0)(&ClientC{}); err == nil {
+		t.Error("WithRateLimit(0) error = nil, want an error")
+	}
+}
@@ -0,0 +1,89 @@
+package duckdns
+
+import (
+	"context"
+	"fmt"
+	"slices"
+	"sort"
+	"sync"
+)
+
+// ReconcileResult structure containing the domains changed by Reconcile
+type ReconcileResult struct {
+	Added     []string
+	Updated   []string
+	Unchanged []string
+	Cleared   []string
+}
+
+// WithReconcileClear option to make Reconcile clear the TXT record of the
+// configured domains missing from the desired records
+func WithReconcileClear(enabled bool) Option {
+	return func(c *ClientC) error {
+		c.reconcileClear = enabled
+		return nil
+	}
+}
+
+// Reconcile function to make the TXT records of the domains match desired,
+// a domain to value map, updating only the records that differ
+func (c *ClientC) Reconcile(ctx context.Context, desired map[string]string) (ReconcileResult, error) {
+	var result ReconcileResult
+	var mu sync.Mutex
+	add := func(list *[]string, domain string) {
+		mu.Lock()
+		*list = append(*list, domain)
+		mu.Unlock()
+	}
+
+	domains := make([]string, 0, len(desired))
+	for domain := range desired {
+		domains = append(domains, domain)
+	}
+	if c.reconcileClear {
+		for _, domain := range c.Config.DomainNames {
+			if _, ok := desired[domain]; !ok {
+				domains = append(domains, domain)
+			}
+		}
+	}
+
+	err := forEachDomain(ctx, domains, func(ctx context.Context, domain string) error {
+		current, err := c.domainTXT(ctx, domain)
+		if err != nil {
+			return err
+		}
+		hasValue := slices.ContainsFunc(current, func(v string) bool { return v != "" })
+
+		value, ok := desired[domain]
+		switch {
+		case !ok && !hasValue:
+			return nil
+		case !ok:
+			if _, err := c.clearRecord(ctx, []string{domain}, ""); err != nil {
+				return err
+			}
+			add(&result.Cleared, domain)
+		case slices.Equal(current, []string{value}):
+			add(&result.Unchanged, domain)
+		default:
+			if _, err := c.UpdateRecordForDomain(ctx, domain, value); err != nil {
+				return err
+			}
+			if hasValue {
+				add(&result.Updated, domain)
+			} else {
+				add(&result.Added, domain)
+			}
+		}
+		return nil
+	})
+
+	for _, list := range [][]string{result.Added, result.Updated, result.Unchanged, result.Cleared} {
+		sort.Strings(list)
+	}
+	if err != nil {
+		return result, fmt.Errorf("reconcile incomplete: %w", err)
+	}
+	return result, nil
+}
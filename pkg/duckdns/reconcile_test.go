@@ -0,0 +1,85 @@
+package duckdns
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"testing"
+)
+
+// newReconcileZone returns a zone holding the current records of the
+// reconcile tests
+func newReconcileZone(t *testing.T) *fakeZone {
+	zone := newFakeZone(t)
+	zone.setTXT("updated.duckdns.org", "old")
+	zone.setTXT("unchanged.duckdns.org", "same")
+	zone.setTXT("extra.duckdns.org", "stale")
+	return zone
+}
+
+var reconcileDesired = map[string]string{
+	"added":     "new",
+	"updated":   "new",
+	"unchanged": "same",
+}
+
+func TestReconcile(t *testing.T) {
+	zone := newReconcileZone(t)
+	var log requestLog
+	c := newTestClient(t, newTestConfig("added", "updated", "unchanged", "extra"), log.wrap(respond(http.StatusOK, "OK")),
+		WithResolver(zone.resolver()))
+
+	result, err := c.Reconcile(context.Background(), reconcileDesired)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	want := ReconcileResult{Added: []string{"added"}, Updated: []string{"updated"}, Unchanged: []string{"unchanged"}}
+	if !reflect.DeepEqual(result, want) {
+		t.Errorf("Reconcile() = %+v, want %+v", result, want)
+	}
+	if got := log.domains(); !reflect.DeepEqual(got, []string{"added", "updated"}) {
+		t.Errorf("updated domains = %q, want [added updated]", got)
+	}
+	for _, req := range log.requests {
+		if q := req.URL.Query(); q.Get("txt") != "new" || q.Has("clear") {
+			t.Errorf("query = %v, want an update to new", q)
+		}
+	}
+}
+
+func TestReconcileClear(t *testing.T) {
+	zone := newReconcileZone(t)
+	var log requestLog
+	c := newTestClient(t, newTestConfig("added", "updated", "unchanged", "extra", "empty"), log.wrap(respond(http.StatusOK, "OK")),
+		WithResolver(zone.resolver()), WithReconcileClear(true))
+
+	result, err := c.Reconcile(context.Background(), reconcileDesired)
+	if err != nil {
+		t.Fatalf("Reconcile() error = %v", err)
+	}
+	if !reflect.DeepEqual(result.Cleared, []string{"extra"}) {
+		t.Errorf("Cleared = %q, want [extra]", result.Cleared)
+	}
+	if got := log.domains(); !reflect.DeepEqual(got, []string{"added", "extra", "updated"}) {
+		t.Errorf("requested domains = %q, want [added extra updated]", got)
+	}
+	for _, req := range log.requests {
+		if q := req.URL.Query(); q.Get("domains") == "extra" && q.Get("clear") != "true" {
+			t.Errorf("query of extra = %v, want a clear", q)
+		}
+	}
+}
+
+func TestReconcileFailure(t *testing.T) {
+	zone := newReconcileZone(t)
+	c := newTestClient(t, newTestConfig("added", "updated", "unchanged"), failDomains("updated"),
+		WithResolver(zone.resolver()))
+
+	result, err := c.Reconcile(context.Background(), reconcileDesired)
+	if err == nil {
+		t.Fatal("Reconcile() error = nil, want the update failure")
+	}
+	if !reflect.DeepEqual(result.Added, []string{"added"}) || len(result.Updated) != 0 {
+		t.Errorf("Reconcile() = %+v, want only added changed", result)
+	}
+}
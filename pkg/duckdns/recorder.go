@@ -0,0 +1,99 @@
+package duckdns
+
+import (
+	"errors"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// recordBodySize bounds the body snippet kept per recorded request
+const recordBodySize = 128
+
+// RequestRecord structure containing the outcome of one request attempt, the
+// token is always redacted
+type RequestRecord struct {
+	URL      string
+	Status   int
+	Duration time.Duration
+	Body     string
+	Error    string
+	Time     time.Time
+}
+
+// requestRecorder is a fixed size ring buffer of the latest request attempts
+type requestRecorder struct {
+	mu      sync.Mutex
+	records []RequestRecord
+	next    int
+	full    bool
+}
+
+// WithRequestRecorder option to keep the last size request attempts, they are
+// returned by RecentRequests
+func WithRequestRecorder(size int) Option {
+	return func(c *ClientC) error {
+		if size <= 0 {
+			return errors.New("request recorder size must be positive")
+		}
+		c.recorder = &requestRecorder{records: make([]RequestRecord, size)}
+		return nil
+	}
+}
+
+func (r *requestRecorder) add(record RequestRecord) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	r.records[r.next] = record
+	r.next = (r.next + 1) % len(r.records)
+	if r.next == 0 {
+		r.full = true
+	}
+}
+
+// recordRequest adds an attempt to the recorder, if any
+func (c *ClientC) recordRequest(urlObf string, resp *http.Response, duration time.Duration, response *Response, err error) {
+	if c.recorder == nil {
+		return
+	}
+
+	record := RequestRecord{
+		URL:      c.obfuscate(urlObf),
+		Duration: duration,
+		Time:     time.Now(),
+	}
+	if resp != nil {
+		record.Status = resp.StatusCode
+	}
+	if response != nil {
+		// masked before the cut, a token straddling it would not be matched
+		body := c.obfuscate(response.Data)
+		if len(body) > recordBodySize {
+			body = body[:recordBodySize]
+		}
+		record.Body = body
+	}
+	if err != nil {
+		record.Error = c.obfuscate(err.Error())
+	}
+
+	c.recorder.add(record)
+}
+
+// RecentRequests function to return the recorded request attempts, oldest
+// first, or nil when the client has no request recorder
+func (c *ClientC) RecentRequests() []RequestRecord {
+	if c.recorder == nil {
+		return nil
+	}
+
+	r := c.recorder
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if !r.full {
+		return append([]RequestRecord(nil), r.records[:r.next]...)
+	}
+	return append(append([]RequestRecord(nil), r.records[r.next:]...), r.records[:r.next]...)
+}
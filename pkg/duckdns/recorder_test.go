@@ -0,0 +1,75 @@
+package duckdns
+
+import (
+	"context"
+	"net/http"
+	"strings"
+	"testing"
+)
+
+func TestRequestRecorderRollsOverAndRedacts(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK echo "+testToken), WithRequestRecorder(2))
+
+	for _, value := range []string{"first", "second", "third"} {
+		if _, err := c.UpdateRecord(context.Background(), value); err != nil {
+			t.Fatalf("UpdateRecord(%q) error = %v", value, err)
+		}
+	}
+
+	records := c.RecentRequests()
+	if len(records) != 2 {
+		t.Fatalf("RecentRequests() has %d records, want 2", len(records))
+	}
+	for i, value := range []string{"second", "third"} {
+		record := records[i]
+		if !strings.Contains(record.URL, "txt="+value) {
+			t.Errorf("record %d URL = %q, want the %s request", i, record.URL, value)
+		}
+		if record.Status != http.StatusOK {
+			t.Errorf("record %d Status = %d, want 200", i, record.Status)
+		}
+		for field, s := range map[string]string{"URL": record.URL, "Body": record.Body} {
+			if strings.Contains(s, testToken) {
+				t.Errorf("record %d %s = %q holds the token", i, field, s)
+			}
+		}
+	}
+}
+
+func TestRequestRecorderPartial(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithRequestRecorder(4))
+	if got := c.RecentRequests(); len(got) != 0 {
+		t.Fatalf("RecentRequests() = %v, want none", got)
+	}
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if got := c.RecentRequests(); len(got) != 1 {
+		t.Errorf("RecentRequests() has %d records, want 1", len(got))
+	}
+}
+
+func TestRecentRequestsWithoutRecorder(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+	if got := c.RecentRequests(); got != nil {
+		t.Errorf("RecentRequests() = %v, want nil", got)
+	}
+}
+
+func TestRequestRecorderTokenAcrossCut(t *testing.T) {
+	// the token starts 10 bytes before the end of the recorded snippet
+	body := "OK " + strings.Repeat("x", recordBodySize-13) + testToken
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, body), WithRequestRecorder(1))
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	record := c.RecentRequests()[0]
+	if strings.Contains(record.Body, testToken[:10]) {
+		t.Errorf("Body = %q holds the start of the token", record.Body)
+	}
+	if want := strings.ReplaceAll(body, testToken, obfuscatedToken); record.Body != want {
+		t.Errorf("Body = %q, want the masked body %q", record.Body, want)
+	}
+}
@@ -0,0 +1,181 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"time"
+)
+
+const (
+	defaultMaxAttempts    = 3
+	defaultRetryBaseDelay = 1 * time.Second
+	defaultRetryMaxDelay  = 10 * time.Second
+	defaultMinAttemptTime = 2 * time.Second
+
+	defaultTimeoutRetries     = 5
+	defaultServerErrorRetries = 2
+	defaultClientErrorRetries = 0
+)
+
+// ErrEmptyResponse is returned when duckdns answers an update with an empty
+// body, in which case the state of the records is unknown
+var ErrEmptyResponse = errors.New("empty response from duckdns")
+
+// ErrInsufficientRetryTime is returned when the context deadline leaves too
+// little time to wait for the backoff and complete another attempt
+var ErrInsufficientRetryTime = errors.New("insufficient time for retry")
+
+// RetryConfig structure containing the retry policy of the client
+type RetryConfig struct {
+	// MaxAttempts bounds the attempts of failures without a class budget
+	// below, e.g. empty responses, 429 and network errors other than timeouts
+	MaxAttempts int
+	BaseDelay   time.Duration
+	MaxDelay    time.Duration
+
+	// TimeoutRetries, ServerErrorRetries and ClientErrorRetries are the
+	// number of retries allowed after a network timeout, a 5xx status and a
+	// 4xx status other than 429. They only apply with the default RetryOn
+	// predicate, a custom one is bounded by MaxAttempts alone.
+	TimeoutRetries     int
+	ServerErrorRetries int
+	ClientErrorRetries int
+
+	// MinAttemptTime is the least time an attempt is expected to need, a
+	// retry is not started when the context deadline is closer than that
+	MinAttemptTime time.Duration
+
+	// RetryOnEmptyResponse treats an empty or whitespace-only body as a
+	// retryable failure rather than a success
+	RetryOnEmptyResponse bool
+
+	// RetryOn decides whether an attempt is retried, resp is nil when the
+	// request itself failed. DefaultRetryOn is used when nil.
+	RetryOn func(resp *http.Response, err error) bool
+}
+
+func defaultRetryConfig() RetryConfig {
+	return RetryConfig{
+		MaxAttempts:          defaultMaxAttempts,
+		BaseDelay:            defaultRetryBaseDelay,
+		MaxDelay:             defaultRetryMaxDelay,
+		MinAttemptTime:       defaultMinAttemptTime,
+		TimeoutRetries:       defaultTimeoutRetries,
+		ServerErrorRetries:   defaultServerErrorRetries,
+		ClientErrorRetries:   defaultClientErrorRetries,
+		RetryOnEmptyResponse: true,
+	}
+}
+
+// backoff returns the delay to wait after the given (1-based) failed attempt,
+// capped at half the time left before the context deadline so that retries
+// don't overshoot it
+func (r *RetryConfig) backoff(ctx context.Context, attempt int) time.Duration {
+	maxDelay := r.MaxDelay
+	if deadline, ok := ctx.Deadline(); ok {
+		maxDelay = min(maxDelay, max(time.Until(deadline)/2, 0))
+	}
+
+	delay := r.BaseDelay << (attempt - 1)
+	if delay <= 0 || delay > maxDelay {
+		delay = maxDelay
+	}
+	return delay
+}
+
+// DefaultRetryOn function is the default retry predicate, it retries network
+// errors, attempt timeouts of the http client included, empty responses,
+// 429 Too Many Requests and 5xx statuses
+func DefaultRetryOn(resp *http.Response, err error) bool {
+	if err != nil {
+		if errors.Is(err, context.Canceled) || err == context.DeadlineExceeded {
+			return false
+		}
+		if errors.Is(err, ErrEmptyResponse) {
+			return true
+		}
+		var netErr net.Error
+		if errors.As(err, &netErr) {
+			return true
+		}
+	}
+
+	if resp == nil {
+		return false
+	}
+	return resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+}
+
+// shouldRetry reports whether an attempt should be retried
+func (r *RetryConfig) shouldRetry(resp *http.Response, err error) bool {
+	retryOn := r.RetryOn
+	if retryOn == nil {
+		retryOn = DefaultRetryOn
+	}
+	return retryOn(resp, err)
+}
+
+// maxAttempts returns the number of attempts allowed for the class of a failed
+// attempt
+func (r *RetryConfig) maxAttempts(resp *http.Response, err error) int {
+	timeout, serverError, clientError := r.classAttempts()
+
+	var netErr net.Error
+	switch {
+	case err != nil && errors.As(err, &netErr) && netErr.Timeout():
+		return timeout
+	case resp != nil && resp.StatusCode >= http.StatusInternalServerError:
+		return serverError
+	case resp != nil && resp.StatusCode >= http.StatusBadRequest && resp.StatusCode != http.StatusTooManyRequests:
+		return clientError
+	}
+	return max(r.MaxAttempts, 1)
+}
+
+// classAttempts returns the attempts allowed after a network timeout, a 5xx
+// and a 4xx status, all MaxAttempts with a custom RetryOn predicate
+func (r *RetryConfig) classAttempts() (timeout, serverError, clientError int) {
+	if r.RetryOn != nil {
+		attempts := max(r.MaxAttempts, 1)
+		return attempts, attempts, attempts
+	}
+	return r.TimeoutRetries + 1, r.ServerErrorRetries + 1, r.ClientErrorRetries + 1
+}
+
+// requestTimeoutKey carries the timeout of a request
+type requestTimeoutKey struct{}
+
+// ContextWithRequestTimeout function to return a context whose requests,
+// retries included, are bounded by timeout. It only ever shortens the
+// deadline of ctx.
+func ContextWithRequestTimeout(ctx context.Context, timeout time.Duration) context.Context {
+	return context.WithValue(ctx, requestTimeoutKey{}, timeout)
+}
+
+// requestContext applies the request timeout carried by ctx, if any
+func requestContext(ctx context.Context) (context.Context, context.CancelFunc) {
+	if ctx == nil {
+		return ctx, func() {}
+	}
+	if timeout, _ := ctx.Value(requestTimeoutKey{}).(time.Duration); timeout > 0 {
+		return context.WithTimeout(ctx, timeout)
+	}
+	return ctx, func() {}
+}
+
+// noRetriesKey marks a context whose requests are attempted once
+type noRetriesKey struct{}
+
+// ContextWithoutRetries function to return a context whose requests make a
+// single attempt, without retry nor fallback base url, e.g. for a liveness
+// check, whatever the retry policy of the client
+func ContextWithoutRetries(ctx context.Context) context.Context {
+	return context.WithValue(ctx, noRetriesKey{}, true)
+}
+
+// retriesDisabled reports whether the requests of ctx are attempted once
+func retriesDisabled(ctx context.Context) bool {
+	return ctx != nil && ctx.Value(noRetriesKey{}) != nil
+}
@@ -0,0 +1,304 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestRetryFailsFastBeforeDeadline(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "")))
+	c.Retry.MinAttemptTime = time.Second
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := c.UpdateRecord(ctx, "value")
+	if !errors.Is(err, ErrInsufficientRetryTime) {
+		t.Fatalf("UpdateRecord() error = %v, want %v", err, ErrInsufficientRetryTime)
+	}
+	if elapsed := time.Since(start); elapsed > 250*time.Millisecond {
+		t.Errorf("UpdateRecord() took %v, want it to fail fast", elapsed)
+	}
+	if got := log.count(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestCustomRetryOn(t *testing.T) {
+	teapot := respond(http.StatusTeapot, "")
+
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(sequence(teapot, teapot, respond(http.StatusOK, "OK"))))
+	var calls int
+	c.Retry.RetryOn = func(resp *http.Response, err error) bool {
+		calls++
+		return resp != nil && resp.StatusCode == http.StatusTeapot
+	}
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if got := log.count(); got != 3 {
+		t.Errorf("requests = %d, want 3", got)
+	}
+	if calls != 2 {
+		t.Errorf("RetryOn calls = %d, want 2", calls)
+	}
+}
+
+func TestDefaultRetryOnSkipsTeapot(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusTeapot, "")))
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err == nil {
+		t.Fatal("UpdateRecord() error = nil, want a 418 error")
+	}
+	if got := log.count(); got != 1 {
+		t.Errorf("requests = %d, want 1", got)
+	}
+}
+
+func TestDefaultRetryOn(t *testing.T) {
+	tests := []struct {
+		name   string
+		status int
+		err    error
+		want   bool
+	}{
+		{name: "ok", status: http.StatusOK},
+		{name: "bad request", status: http.StatusBadRequest},
+		{name: "request timeout", status: http.StatusRequestTimeout},
+		{name: "too many requests", status: http.StatusTooManyRequests, want: true},
+		{name: "internal server error", status: http.StatusInternalServerError, want: true},
+		{name: "bad gateway", status: http.StatusBadGateway, want: true},
+		{name: "empty response", status: http.StatusOK, err: ErrEmptyResponse, want: true},
+		{name: "network error", err: &net.OpError{Op: "dial", Err: errors.New("refused")}, want: true},
+		{name: "canceled", err: context.Canceled},
+		{name: "deadline", err: context.DeadlineExceeded},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var resp *http.Response
+			if tt.status != 0 {
+				resp = &http.Response{StatusCode: tt.status}
+			}
+			if got := DefaultRetryOn(resp, tt.err); got != tt.want {
+				t.Errorf("DefaultRetryOn() = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+// stall returns a handler answering only once the client went away
+func stall(w http.ResponseWriter, r *http.Request) {
+	<-r.Context().Done()
+}
+
+func TestRetryAttemptsPerClass(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		want    int
+	}{
+		{name: "timeout", handler: stall, want: 6},
+		{name: "server error", handler: respond(http.StatusServiceUnavailable, ""), want: 3},
+		{name: "client error", handler: respond(http.StatusBadRequest, ""), want: 1},
+		{name: "too many requests", handler: respond(http.StatusTooManyRequests, ""), want: 3},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log requestLog
+			c := newTestClient(t, newTestConfig(), log.wrap(tt.handler))
+			c.httpClient.Timeout = 20 * time.Millisecond
+
+			resp, err := c.UpdateRecord(context.Background(), "value")
+			if err == nil {
+				t.Fatal("UpdateRecord() error = nil, want a failure")
+			}
+			if got := log.count(); got != tt.want {
+				t.Errorf("requests = %d, want %d", got, tt.want)
+			}
+			if resp.Attempts != tt.want {
+				t.Errorf("Attempts = %d, want %d", resp.Attempts, tt.want)
+			}
+		})
+	}
+}
+
+func TestRetryAttemptsPerClassConfigured(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusServiceUnavailable, "")))
+	c.Retry.ServerErrorRetries = 4
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err == nil {
+		t.Fatal("UpdateRecord() error = nil, want a failure")
+	}
+	if got := log.count(); got != 5 {
+		t.Errorf("requests = %d, want 5", got)
+	}
+}
+
+func TestBackoff(t *testing.T) {
+	r := defaultRetryConfig()
+
+	tests := []struct {
+		name      string
+		remaining time.Duration
+		attempt   int
+		min, max  time.Duration
+	}{
+		{name: "no deadline first", attempt: 1, min: time.Second, max: time.Second},
+		{name: "no deadline doubling", attempt: 3, min: 4 * time.Second, max: 4 * time.Second},
+		{name: "no deadline capped", attempt: 10, min: 10 * time.Second, max: 10 * time.Second},
+		{name: "far deadline", remaining: time.Hour, attempt: 3, min: 4 * time.Second, max: 4 * time.Second},
+		{name: "near deadline", remaining: 4 * time.Second, attempt: 3, min: 1900 * time.Millisecond, max: 2 * time.Second},
+		{name: "nearer deadline", remaining: time.Second, attempt: 3, min: 450 * time.Millisecond, max: 500 * time.Millisecond},
+		{name: "past deadline", remaining: -time.Second, attempt: 1, min: 0, max: 0},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx := context.Background()
+			if tt.remaining != 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.remaining)
+				defer cancel()
+			}
+			if got := r.backoff(ctx, tt.attempt); got < tt.min || got > tt.max {
+				t.Errorf("backoff(%d) = %v, want within [%v, %v]", tt.attempt, got, tt.min, tt.max)
+			}
+		})
+	}
+}
+
+func TestContextWithRequestTimeout(t *testing.T) {
+	tests := []struct {
+		name     string
+		timeout  time.Duration
+		deadline time.Duration
+	}{
+		{name: "tighter than the context", timeout: 50 * time.Millisecond, deadline: time.Minute},
+		{name: "without context deadline", timeout: 50 * time.Millisecond},
+		{name: "looser than the context", timeout: time.Minute, deadline: 50 * time.Millisecond},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			c := newTestClient(t, newTestConfig(), stall)
+
+			ctx := context.Background()
+			if tt.deadline > 0 {
+				var cancel context.CancelFunc
+				ctx, cancel = context.WithTimeout(ctx, tt.deadline)
+				defer cancel()
+			}
+
+			start := time.Now()
+			_, err := c.UpdateRecord(ContextWithRequestTimeout(ctx, tt.timeout), "value")
+			if !errors.Is(err, context.DeadlineExceeded) {
+				t.Fatalf("UpdateRecord() error = %v, want %v", err, context.DeadlineExceeded)
+			}
+			if elapsed := time.Since(start); elapsed > time.Second {
+				t.Errorf("UpdateRecord() took %v, want the 50ms bound honored", elapsed)
+			}
+		})
+	}
+}
+
+func TestResponseAttempts(t *testing.T) {
+	var calls int
+	transport := roundTripFunc(func(req *http.Request) (*http.Response, error) {
+		calls++
+		if calls <= 2 {
+			return nil, &net.OpError{Op: "dial", Net: "tcp", Err: errors.New("connection refused")}
+		}
+		return &http.Response{StatusCode: http.StatusOK, Status: "200 OK", Body: io.NopCloser(strings.NewReader("OK")), Request: req}, nil
+	})
+	c := NewClient(&http.Client{Transport: transport}, newTestConfig())
+	c.Retry.BaseDelay = time.Millisecond
+
+	resp, err := c.UpdateRecord(context.Background(), "value")
+	if err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if resp.Attempts != 3 {
+		t.Errorf("Attempts = %d, want 3", resp.Attempts)
+	}
+}
+
+func TestResponseAttemptsSingle(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+
+	resp, err := c.UpdateRecord(context.Background(), "value")
+	if err != nil || resp.Attempts != 1 {
+		t.Fatalf("UpdateRecord() = %d attempts, %v, want 1 attempt", resp.Attempts, err)
+	}
+}
+
+func TestContextWithoutRetries(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+	}{
+		{name: "server error", handler: respond(http.StatusServiceUnavailable, "")},
+		{name: "empty response", handler: respond(http.StatusOK, "")},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var log requestLog
+			c := newTestClient(t, newTestConfig(), log.wrap(tt.handler))
+			c.Retry.MaxAttempts = 5
+
+			resp, err := c.UpdateRecord(ContextWithoutRetries(context.Background()), "value")
+			if err == nil {
+				t.Fatal("UpdateRecord() error = nil, want a failure")
+			}
+			if got := log.count(); got != 1 {
+				t.Errorf("requests = %d, want 1", got)
+			}
+			if resp.Attempts != 1 {
+				t.Errorf("Attempts = %d, want 1", resp.Attempts)
+			}
+		})
+	}
+}
+
+func TestContextWithoutRetriesSkipsFallback(t *testing.T) {
+	var fallback requestLog
+	srv := httptest.NewServer(fallback.wrap(respond(http.StatusOK, "OK")))
+	t.Cleanup(srv.Close)
+
+	var primary requestLog
+	c := newTestClient(t, newTestConfig(), primary.wrap(respond(http.StatusServiceUnavailable, "")),
+		WithFallbackBaseURL(srv.URL))
+
+	if _, err := c.UpdateRecord(ContextWithoutRetries(context.Background()), "value"); err == nil {
+		t.Fatal("UpdateRecord() error = nil, want the primary failure")
+	}
+	if got := primary.count(); got != 1 {
+		t.Errorf("primary requests = %d, want 1", got)
+	}
+	if got := fallback.count(); got != 0 {
+		t.Errorf("fallback requests = %d, want 0", got)
+	}
+
+	// other calls keep the retry policy and the fallback
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if got := fallback.count(); got != 1 {
+		t.Errorf("fallback requests = %d, want 1", got)
+	}
+}
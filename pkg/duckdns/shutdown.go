@@ -0,0 +1,38 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+)
+
+// ErrShuttingDown is returned by the requests cancelled by Shutdown and the
+// requests started after it
+var ErrShuttingDown = errors.New("duckdns client is shutting down")
+
+// Shutdown function to cancel every in-flight request and make new ones fail
+// fast with ErrShuttingDown, e.g. to drain the webhook on SIGTERM. The idle
+// connections of the package-managed transport are closed.
+func (c *ClientC) Shutdown() {
+	if c.shutdown != nil {
+		c.shutdown(ErrShuttingDown)
+	}
+	c.Close()
+}
+
+// shutdownContext returns ctx cancelled with ErrShuttingDown on Shutdown, or
+// ErrShuttingDown when the client is already shut down
+func (c *ClientC) shutdownContext(ctx context.Context) (context.Context, context.CancelFunc, error) {
+	if c.shutdownCtx == nil || ctx == nil {
+		return ctx, func() {}, nil
+	}
+	if c.shutdownCtx.Err() != nil {
+		return nil, nil, ErrShuttingDown
+	}
+
+	ctx, cancel := context.WithCancelCause(ctx)
+	stop := context.AfterFunc(c.shutdownCtx, func() { cancel(ErrShuttingDown) })
+	return ctx, func() {
+		stop()
+		cancel(context.Canceled)
+	}, nil
+}
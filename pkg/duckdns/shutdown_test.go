@@ -0,0 +1,65 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+func TestShutdownCancelsInFlight(t *testing.T) {
+	started := make(chan struct{}, 1)
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		stall(w, r)
+	}))
+
+	errc := make(chan error, 1)
+	go func() {
+		_, err := c.UpdateRecord(context.Background(), "value")
+		errc <- err
+	}()
+
+	<-started
+	c.Shutdown()
+
+	select {
+	case err := <-errc:
+		if !errors.Is(err, ErrShuttingDown) {
+			t.Fatalf("UpdateRecord() error = %v, want %v", err, ErrShuttingDown)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("UpdateRecord() still running a second after Shutdown()")
+	}
+	if got := log.count(); got != 1 {
+		t.Errorf("requests = %d, want no retry after Shutdown()", got)
+	}
+}
+
+func TestShutdownRejectsNewRequests(t *testing.T) {
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, "OK")))
+
+	c.Shutdown()
+	c.Shutdown()
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); !errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("UpdateRecord() error = %v, want %v", err, ErrShuttingDown)
+	}
+	if got := log.count(); got != 0 {
+		t.Errorf("requests = %d, want 0", got)
+	}
+}
+
+func TestShutdownLeavesCallerCancellation(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), stall)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+	_, err := c.UpdateRecord(ctx, "value")
+	if err == nil || errors.Is(err, ErrShuttingDown) {
+		t.Fatalf("UpdateRecord() error = %v, want the caller deadline only", err)
+	}
+}
@@ -4,11 +4,9 @@ import (
 	"context"
 	"fmt"
 	"net/http"
-	"strings"
 
 	"github.com/cert-manager/cert-manager/pkg/acme/webhook"
 	"github.com/cert-manager/cert-manager/pkg/acme/webhook/apis/acme/v1alpha1"
-	"github.com/cert-manager/cert-manager/pkg/issuer/acme/dns/util"
 
 	"github.com/pkg/errors"
 
@@ -82,15 +80,7 @@ func (s *duckDNSProviderSolver) getDNSName(ch *v1alpha1.ChallengeRequest) []stri
 	dnsFromChallenge := ch.DNSName //full dns name, is of the form '<domain>.duckdns.org' from normal hostnames, '<suffix>.<domain>.duckdns.org' and '*.<domain>.duckdns.org' for wildcards.
 	klog.Infof("DNSName from ChallengeRequest is %v", dnsFromChallenge)
 
-	duckdnszone := strings.TrimSuffix(dnsFromChallenge, "duckdns.org") //<suffix>.<domain>. or <domain>.
-	duckdnszone = util.UnFqdn(duckdnszone)                             //<suffix>.<domain> or <domain>
-	split := strings.Split(duckdnszone, ".")
-
-	if len(split) > 1 {
-		klog.Infof("Got dns domain from challenge %v", split[len(split)-1])
-		out = append(out, split[len(split)-1])
-		return out
-	}
+	duckdnszone := domainFromFQDN(dnsFromChallenge)
 
 	klog.Infof("Got dns domain from challenge %v", duckdnszone)
 	out = append(out, duckdnszone)
@@ -0,0 +1,67 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"sync"
+)
+
+// ErrSuperseded is returned by an update canceled because a newer update of
+// one of its domains started
+var ErrSuperseded = errors.New("update superseded by a newer update")
+
+// superseder tracks the in-flight TXT update of each domain
+type superseder struct {
+	mu       sync.Mutex
+	inflight map[string]*inflightUpdate
+}
+
+type inflightUpdate struct {
+	cancel context.CancelCauseFunc
+}
+
+// WithSupersede option to make a TXT update cancel every in-flight TXT update
+// sharing a domain with it, so a stale value can't land after a newer one
+func WithSupersede(enabled bool) Option {
+	return func(c *ClientC) error {
+		if enabled {
+			c.superseder = &superseder{inflight: make(map[string]*inflightUpdate)}
+		} else {
+			c.superseder = nil
+		}
+		return nil
+	}
+}
+
+// start registers an update of domains, canceling every in-flight update of
+// any of them, and returns the context of the update and the function to call
+// once it is done
+func (s *superseder) start(ctx context.Context, domains []string) (context.Context, func()) {
+	ctx, cancel := context.WithCancelCause(ctx)
+	update := &inflightUpdate{cancel: cancel}
+
+	keys := make([]string, 0, len(domains))
+	for _, domain := range domains {
+		keys = append(keys, ChallengeKey(domain, ""))
+	}
+
+	s.mu.Lock()
+	for _, key := range keys {
+		if prev, ok := s.inflight[key]; ok && prev != update {
+			prev.cancel(ErrSuperseded)
+		}
+		s.inflight[key] = update
+	}
+	s.mu.Unlock()
+
+	return ctx, func() {
+		s.mu.Lock()
+		for _, key := range keys {
+			if s.inflight[key] == update {
+				delete(s.inflight, key)
+			}
+		}
+		s.mu.Unlock()
+		cancel(nil)
+	}
+}
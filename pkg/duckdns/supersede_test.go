@@ -0,0 +1,101 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+	"time"
+)
+
+// blockingHandler returns a handler reporting the txt value of every request
+// on started and holding the requests of value blocked until release is
+// closed or the client goes away
+func blockingHandler(value string, started chan<- string, release <-chan struct{}) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		txt := r.URL.Query().Get("txt")
+		started <- txt
+		if txt == value {
+			select {
+			case <-release:
+			case <-r.Context().Done():
+				return
+			}
+		}
+		w.Write([]byte("OK"))
+	}
+}
+
+func TestSupersedeCancelsSameDomain(t *testing.T) {
+	started := make(chan string, 4)
+	release := make(chan struct{})
+	defer close(release)
+	c := newTestClient(t, newTestConfig(), blockingHandler("old", started, release), WithSupersede(true))
+
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := c.UpdateRecord(context.Background(), "old")
+		firstErr <- err
+	}()
+	if got := <-started; got != "old" {
+		t.Fatalf("first request txt = %q, want old", got)
+	}
+
+	if _, err := c.UpdateRecord(context.Background(), "new"); err != nil {
+		t.Fatalf("second UpdateRecord() error = %v", err)
+	}
+	if err := <-firstErr; !errors.Is(err, ErrSuperseded) {
+		t.Errorf("first UpdateRecord() error = %v, want %v", err, ErrSuperseded)
+	}
+	if got := <-started; got != "new" {
+		t.Errorf("second request txt = %q, want new", got)
+	}
+}
+
+func TestSupersedeKeepsOtherDomains(t *testing.T) {
+	started := make(chan string, 4)
+	release := make(chan struct{})
+	c := newTestClient(t, newTestConfig("first", "second"), blockingHandler("old", started, release), WithSupersede(true))
+
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := c.UpdateRecordForDomain(context.Background(), "first", "old")
+		firstErr <- err
+	}()
+	<-started
+
+	if _, err := c.UpdateRecordForDomain(context.Background(), "second", "new"); err != nil {
+		t.Fatalf("UpdateRecordForDomain(second) error = %v", err)
+	}
+	select {
+	case err := <-firstErr:
+		t.Fatalf("UpdateRecordForDomain(first) returned %v, want it still in flight", err)
+	case <-time.After(50 * time.Millisecond):
+	}
+
+	close(release)
+	if err := <-firstErr; err != nil {
+		t.Errorf("UpdateRecordForDomain(first) error = %v", err)
+	}
+}
+
+func TestSupersedeOverlappingDomains(t *testing.T) {
+	started := make(chan string, 4)
+	release := make(chan struct{})
+	defer close(release)
+	c := newTestClient(t, newTestConfig("first", "second"), blockingHandler("old", started, release), WithSupersede(true))
+
+	firstErr := make(chan error, 1)
+	go func() {
+		_, err := c.UpdateRecordDomains(context.Background(), []string{"first", "second"}, "old")
+		firstErr <- err
+	}()
+	<-started
+
+	if _, err := c.UpdateRecordForDomain(context.Background(), "second", "new"); err != nil {
+		t.Fatalf("UpdateRecordForDomain(second) error = %v", err)
+	}
+	if err := <-firstErr; !errors.Is(err, ErrSuperseded) {
+		t.Errorf("UpdateRecordDomains() error = %v, want %v", err, ErrSuperseded)
+	}
+}
@@ -0,0 +1,91 @@
+package duckdns
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+// defaultTokenExpiryWarning is the warning window used when the config sets
+// a TokenMaxAge but no TokenExpiryWarning
+const defaultTokenExpiryWarning = 7 * 24 * time.Hour
+
+// readTokenFile returns the trimmed token stored in path
+func readTokenFile(path string) (string, error) {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return "", fmt.Errorf("unable to read token file: %v", err)
+	}
+
+	token := strings.TrimSpace(string(data))
+	if token == "" {
+		return "", fmt.Errorf("token file %q is empty", path)
+	}
+	return token, nil
+}
+
+// LoadTokenFile function to set the token from the TokenFile of the config
+func (c *ConfigC) LoadTokenFile() error {
+	if c.TokenFile == "" {
+		return errors.New("no token file configured")
+	}
+
+	token, err := readTokenFile(c.TokenFile)
+	if err != nil {
+		return err
+	}
+	c.Token = token
+	return nil
+}
+
+// ReloadToken function to re-read the token from Config.TokenFile, so tokens
+// can be rotated without a restart. Only the file-based token source supports
+// reload. Safe to call while requests are in flight.
+func (c *ClientC) ReloadToken() error {
+	if c.Config.TokenFile == "" {
+		return errors.New("token reload requires a token file")
+	}
+
+	token, err := readTokenFile(c.Config.TokenFile)
+	if err != nil {
+		return err
+	}
+
+	c.tokenMu.Lock()
+	c.Config.Token = token
+	c.tokenMu.Unlock()
+	return nil
+}
+
+// token returns the current token
+func (c *ClientC) token() string {
+	c.tokenMu.RLock()
+	defer c.tokenMu.RUnlock()
+	return c.Config.Token
+}
+
+// CheckTokenAge function to warn when, at now, the token is within the expiry
+// warning window of its max age, and report whether it is. It does nothing
+// without a TokenIssuedAt and TokenMaxAge.
+func (c *ClientC) CheckTokenAge(now time.Time) bool {
+	if c.Config.TokenIssuedAt.IsZero() || c.Config.TokenMaxAge <= 0 {
+		return false
+	}
+
+	window := c.Config.TokenExpiryWarning
+	if window <= 0 {
+		window = defaultTokenExpiryWarning
+	}
+
+	expiry := c.Config.TokenIssuedAt.Add(c.Config.TokenMaxAge)
+	if now.Before(expiry.Add(-window)) {
+		return false
+	}
+
+	klog.Warningf("%sDuckdns token issued at %v is due for rotation by %v", c.logPrefix, c.Config.TokenIssuedAt.Format(time.RFC3339), expiry.Format(time.RFC3339))
+	return true
+}
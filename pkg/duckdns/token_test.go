@@ -0,0 +1,131 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestEmptyTokenAtRequestTime(t *testing.T) {
+	var log requestLog
+	config := newTestConfig()
+	c := newTestClient(t, config, log.wrap(respond(http.StatusOK, "OK")))
+	config.Token = ""
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); !errors.Is(err, ErrEmptyToken) {
+		t.Fatalf("UpdateRecord() error = %v, want %v", err, ErrEmptyToken)
+	}
+	if _, err := c.UpdateIP(context.Background()); !errors.Is(err, ErrEmptyToken) {
+		t.Fatalf("UpdateIP() error = %v, want %v", err, ErrEmptyToken)
+	}
+	if got := log.count(); got != 0 {
+		t.Errorf("requests = %d, want 0", got)
+	}
+}
+
+func TestReloadToken(t *testing.T) {
+	const rotated = "fedcba98-7654-3210-fedc-ba9876543210"
+
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte(testToken+"\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+
+	var log requestLog
+	c := newTestClient(t, &ConfigC{TokenFile: path, DomainNames: []string{"example"}}, log.wrap(respond(http.StatusOK, "OK")))
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if got := log.last(t).URL.Query().Get("token"); got != testToken {
+		t.Errorf("token = %q, want the file token", got)
+	}
+
+	if err := os.WriteFile(path, []byte(rotated), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	// reloads race with in-flight requests
+	var wg sync.WaitGroup
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			c.UpdateRecord(context.Background(), "value")
+		}()
+	}
+	if err := c.ReloadToken(); err != nil {
+		t.Fatalf("ReloadToken() error = %v", err)
+	}
+	wg.Wait()
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if got := log.last(t).URL.Query().Get("token"); got != rotated {
+		t.Errorf("token = %q, want the rotated token", got)
+	}
+}
+
+func TestReloadTokenFailure(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+	if err := c.ReloadToken(); err == nil {
+		t.Error("ReloadToken() error = nil without a token file")
+	}
+
+	path := filepath.Join(t.TempDir(), "token")
+	if err := os.WriteFile(path, []byte("\n"), 0o600); err != nil {
+		t.Fatal(err)
+	}
+	c.Config.TokenFile = path
+	if err := c.ReloadToken(); err == nil {
+		t.Error("ReloadToken() error = nil with an empty token file")
+	}
+	if c.token() != testToken {
+		t.Errorf("token = %q after a failed reload, want it unchanged", c.token())
+	}
+}
+
+func TestCheckTokenAge(t *testing.T) {
+	issued := time.Date(2026, 1, 1, 0, 0, 0, 0, time.UTC)
+	day := 24 * time.Hour
+
+	tests := []struct {
+		name    string
+		maxAge  time.Duration
+		warning time.Duration
+		now     time.Time
+		want    bool
+	}{
+		{name: "fresh", maxAge: 90 * day, now: issued.Add(day)},
+		{name: "just before the default window", maxAge: 90 * day, now: issued.Add(83*day - time.Second)},
+		{name: "at the default window", maxAge: 90 * day, now: issued.Add(83 * day), want: true},
+		{name: "expired", maxAge: 90 * day, now: issued.Add(100 * day), want: true},
+		{name: "before a custom window", maxAge: 90 * day, warning: 30 * day, now: issued.Add(59 * day)},
+		{name: "in a custom window", maxAge: 90 * day, warning: 30 * day, now: issued.Add(61 * day), want: true},
+		{name: "no max age", now: issued.Add(1000 * day)},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig()
+			c := newTestClient(t, config, respond(http.StatusOK, "OK"))
+			config.TokenIssuedAt = issued
+			config.TokenMaxAge = tt.maxAge
+			config.TokenExpiryWarning = tt.warning
+
+			logs := captureLogs(t, 0)
+			if got := c.CheckTokenAge(tt.now); got != tt.want {
+				t.Errorf("CheckTokenAge() = %v, want %v", got, tt.want)
+			}
+			warned := len(logs.lines("due for rotation")) == 1
+			if warned != tt.want {
+				t.Errorf("rotation warning logged = %v, want %v", warned, tt.want)
+			}
+		})
+	}
+}
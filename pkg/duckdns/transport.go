@@ -0,0 +1,172 @@
+package duckdns
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"time"
+
+	"k8s.io/klog/v2"
+)
+
+const (
+	defaultDialTimeout   = 30 * time.Second
+	defaultDialKeepAlive = 30 * time.Second
+)
+
+// newManagedTransport returns the transport used when the caller does not
+// supply its own http client
+func newManagedTransport() *http.Transport {
+	return http.DefaultTransport.(*http.Transport).Clone()
+}
+
+// OwnsTransport function to report whether the client manages its own
+// transport, i.e. it was built with a nil http client. Only then do the
+// transport options and Close take effect.
+func (c *ClientC) OwnsTransport() bool {
+	return c.transport != nil
+}
+
+// WithIPFamily option to force the package-managed transport to dial over
+// "tcp4" or "tcp6", e.g. so that UpdateIP registers the IPv6 address of a
+// dual-stack host. Ignored when the caller supplied the http client.
+func WithIPFamily(network string) Option {
+	return func(c *ClientC) error {
+		switch network {
+		case "tcp", "tcp4", "tcp6":
+		default:
+			return fmt.Errorf("unsupported ip family %q", network)
+		}
+
+		if c.transport == nil {
+			klog.Warningf("%sIgnoring ip family %v, http client is not managed by the duckdns client", c.logPrefix, network)
+			return nil
+		}
+
+		dialer := &net.Dialer{Timeout: defaultDialTimeout, KeepAlive: defaultDialKeepAlive}
+		c.transport.DialContext = func(ctx context.Context, _, addr string) (net.Conn, error) {
+			conn, err := dialer.DialContext(ctx, network, addr)
+			if err != nil && network == "tcp6" {
+				return nil, fmt.Errorf("no ipv6 connectivity to %v: %w", addr, err)
+			}
+			return conn, err
+		}
+		return nil
+	}
+}
+
+// WithHTTP2 option to enable or disable HTTP/2 on the package-managed
+// transport, disabling it forces HTTP/1.1. Ignored when the caller supplied
+// the http client.
+func WithHTTP2(enabled bool) Option {
+	return func(c *ClientC) error {
+		if c.transport == nil {
+			klog.Warning(c.logPrefix + "Ignoring http2 setting, http client is not managed by the duckdns client")
+			return nil
+		}
+
+		c.transport.ForceAttemptHTTP2 = enabled
+		if enabled {
+			c.transport.TLSNextProto = nil
+		} else {
+			c.transport.TLSNextProto = map[string]func(string, *tls.Conn) http.RoundTripper{}
+		}
+		return nil
+	}
+}
+
+// WithDisableKeepAlives option to disable keep-alives on the package-managed
+// transport, so short-lived processes don't hold idle connections. Ignored
+// when the caller supplied the http client.
+func WithDisableKeepAlives() Option {
+	return func(c *ClientC) error {
+		if c.transport == nil {
+			klog.Warning(c.logPrefix + "Ignoring disable keep-alives, http client is not managed by the duckdns client")
+			return nil
+		}
+
+		c.transport.DisableKeepAlives = true
+		return nil
+	}
+}
+
+// Close function to close the idle connections of the package-managed
+// transport, it does nothing when the caller supplied the http client or
+// keep-alives are disabled
+func (c *ClientC) Close() {
+	if c.transport == nil {
+		return
+	}
+	c.transport.CloseIdleConnections()
+}
+
+// RedirectPolicy decides how the package-managed http client handles the
+// redirects of duckdns or a proxy, a redirect to another host may carry the
+// token in its url
+type RedirectPolicy int
+
+const (
+	// RedirectRefuseCrossHost follows redirects to the same host only, it is
+	// the default of the package-managed http client
+	RedirectRefuseCrossHost RedirectPolicy = iota
+
+	// RedirectLog follows every redirect and logs those to another host
+	RedirectLog
+)
+
+// maxRedirects is the redirect limit of the package-managed http client,
+// the same as the http package default
+const maxRedirects = 10
+
+// ErrCrossHostRedirect is returned when a redirect to another host is
+// refused
+var ErrCrossHostRedirect = errors.New("refusing redirect to another host")
+
+// ErrInsecureRedirect is returned when a redirect from https to http is
+// refused, whatever the redirect policy, as it would send the token in clear
+var ErrInsecureRedirect = errors.New("refusing redirect from https to http")
+
+// WithRedirectPolicy option to set how the package-managed http client
+// handles redirects. Ignored when the caller supplied the http client.
+func WithRedirectPolicy(policy RedirectPolicy) Option {
+	return func(c *ClientC) error {
+		if policy != RedirectRefuseCrossHost && policy != RedirectLog {
+			return fmt.Errorf("unsupported redirect policy %d", policy)
+		}
+
+		if c.transport == nil {
+			klog.Warning(c.logPrefix + "Ignoring redirect policy, http client is not managed by the duckdns client")
+			return nil
+		}
+
+		c.httpClient.CheckRedirect = c.checkRedirect(policy)
+		return nil
+	}
+}
+
+// checkRedirect returns the CheckRedirect function enforcing policy
+func (c *ClientC) checkRedirect(policy RedirectPolicy) func(req *http.Request, via []*http.Request) error {
+	return func(req *http.Request, via []*http.Request) error {
+		if len(via) >= maxRedirects {
+			return fmt.Errorf("stopped after %d redirects", maxRedirects)
+		}
+
+		original := via[0].URL
+		if original.Scheme == "https" && req.URL.Scheme != "https" {
+			return fmt.Errorf("%w: %v to %v", ErrInsecureRedirect, original.Host, req.URL.Host)
+		}
+
+		from := original.Host
+		if req.URL.Host == from {
+			return nil
+		}
+		if policy == RedirectLog {
+			klog.Warningf("%sFollowing redirect from %v to %v", c.logPrefix, from, req.URL.Host)
+			return nil
+		}
+		return fmt.Errorf("%w: %v to %v", ErrCrossHostRedirect, from, req.URL.Host)
+	}
+}
@@ -0,0 +1,223 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+// newManagedTestClient returns a client managing its own transport, sending
+// its requests to a test server running handler
+func newManagedTestClient(t *testing.T, handler http.HandlerFunc, opts ...Option) *ClientC {
+	t.Helper()
+
+	srv := httptest.NewServer(handler)
+	t.Cleanup(srv.Close)
+
+	c := NewClient(nil, newTestConfig(), opts...)
+	c.BaseURL = srv.URL
+	c.Retry.MaxAttempts = 1
+	t.Cleanup(c.Close)
+	return c
+}
+
+func TestWithIPFamily(t *testing.T) {
+	tests := []struct {
+		network string
+		wantErr string
+	}{
+		{network: "tcp"},
+		{network: "tcp4"},
+		{network: "tcp6", wantErr: "no ipv6 connectivity"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.network, func(t *testing.T) {
+			// the test server only listens on 127.0.0.1
+			c := newManagedTestClient(t, respond(http.StatusOK, "OK"), WithIPFamily(tt.network))
+
+			_, err := c.UpdateIP(context.Background())
+			if tt.wantErr == "" {
+				if err != nil {
+					t.Fatalf("UpdateIP() error = %v", err)
+				}
+				return
+			}
+			if err == nil || !strings.Contains(err.Error(), tt.wantErr) {
+				t.Fatalf("UpdateIP() error = %v, want %q", err, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestWithIPFamilyInvalid(t *testing.T) {
+	c := &ClientC{transport: newManagedTransport()}
+	if err := WithIPFamily("udp")(c); err == nil {
+		t.Error("WithIPFamily(udp) error = nil, want an error")
+	}
+}
+
+func TestWithIPFamilySuppliedClient(t *testing.T) {
+	// the option does not apply to a supplied http client, whose requests
+	// keep reaching the ipv4 test server
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithIPFamily("tcp6"))
+	if _, err := c.UpdateIP(context.Background()); err != nil {
+		t.Fatalf("UpdateIP() error = %v", err)
+	}
+}
+
+func TestWithHTTP2(t *testing.T) {
+	enabled := NewClient(nil, newTestConfig(), WithHTTP2(true))
+	if !enabled.transport.ForceAttemptHTTP2 || enabled.transport.TLSNextProto != nil {
+		t.Errorf("WithHTTP2(true): ForceAttemptHTTP2 = %v, TLSNextProto = %v, want true and nil",
+			enabled.transport.ForceAttemptHTTP2, enabled.transport.TLSNextProto)
+	}
+
+	disabled := NewClient(nil, newTestConfig(), WithHTTP2(false))
+	if disabled.transport.ForceAttemptHTTP2 || disabled.transport.TLSNextProto == nil || len(disabled.transport.TLSNextProto) != 0 {
+		t.Errorf("WithHTTP2(false): ForceAttemptHTTP2 = %v, TLSNextProto = %v, want false and an empty map",
+			disabled.transport.ForceAttemptHTTP2, disabled.transport.TLSNextProto)
+	}
+}
+
+func TestWithHTTP2SuppliedClient(t *testing.T) {
+	transport := &http.Transport{}
+	c := NewClient(&http.Client{Transport: transport}, newTestConfig(), WithHTTP2(false))
+	if c.OwnsTransport() || transport.TLSNextProto != nil {
+		t.Errorf("WithHTTP2(false) changed a supplied transport")
+	}
+}
+
+func TestWithDisableKeepAlives(t *testing.T) {
+	var log requestLog
+	c := newManagedTestClient(t, log.wrap(respond(http.StatusOK, "OK")), WithDisableKeepAlives())
+	if !c.transport.DisableKeepAlives {
+		t.Fatal("DisableKeepAlives = false, want true")
+	}
+
+	if _, err := c.UpdateIP(context.Background()); err != nil {
+		t.Fatalf("UpdateIP() error = %v", err)
+	}
+	// no connection is left idle, there is nothing for Close to close
+	if req := log.last(t); !req.Close {
+		t.Error("request did not ask to close the connection")
+	}
+	c.Close()
+	c.Close()
+}
+
+func TestWithDisableKeepAlivesSuppliedClient(t *testing.T) {
+	transport := &http.Transport{}
+	c := NewClient(&http.Client{Transport: transport}, newTestConfig(), WithDisableKeepAlives())
+	if transport.DisableKeepAlives {
+		t.Error("WithDisableKeepAlives() changed a supplied transport")
+	}
+	c.Close()
+}
+
+func TestOwnsTransport(t *testing.T) {
+	owned := NewClient(nil, newTestConfig())
+	if !owned.OwnsTransport() {
+		t.Error("OwnsTransport() = false for a nil http client, want true")
+	}
+	owned.Close()
+
+	supplied := NewClient(&http.Client{}, newTestConfig())
+	if supplied.OwnsTransport() {
+		t.Error("OwnsTransport() = true for a supplied http client, want false")
+	}
+	// closing a supplied client is a no-op
+	supplied.Close()
+}
+
+// redirectTo returns a handler redirecting every request to target, keeping
+// its path and query
+func redirectTo(target string) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, target+r.URL.RequestURI(), http.StatusFound)
+	}
+}
+
+func TestRedirectPolicy(t *testing.T) {
+	tests := []struct {
+		name        string
+		opts        []Option
+		wantErr     error
+		wantWarning bool
+	}{
+		{name: "default refuses cross host", wantErr: ErrCrossHostRedirect},
+		{name: "log follows", opts: []Option{WithRedirectPolicy(RedirectLog)}, wantWarning: true},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			logs := captureLogs(t, 0)
+
+			var target requestLog
+			other := httptest.NewServer(target.wrap(respond(http.StatusOK, "OK")))
+			t.Cleanup(other.Close)
+
+			c := newManagedTestClient(t, redirectTo(other.URL), tt.opts...)
+			_, err := c.UpdateRecord(context.Background(), "value")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateRecord() error = %v, want %v", err, tt.wantErr)
+			}
+			if err != nil && strings.Contains(err.Error(), testToken) {
+				t.Errorf("error %q holds the token", err)
+			}
+
+			wantRequests := 0
+			if tt.wantErr == nil {
+				wantRequests = 1
+			}
+			if got := target.count(); got != wantRequests {
+				t.Errorf("requests to the other host = %d, want %d", got, wantRequests)
+			}
+			if got := len(logs.lines("Following redirect")) == 1; got != tt.wantWarning {
+				t.Errorf("redirect warning logged = %v, want %v", got, tt.wantWarning)
+			}
+		})
+	}
+}
+
+func TestRedirectPolicySameHost(t *testing.T) {
+	var log requestLog
+	c := newManagedTestClient(t, log.wrap(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/update" {
+			http.Redirect(w, r, "/v2"+r.URL.RequestURI(), http.StatusFound)
+			return
+		}
+		w.Write([]byte("OK"))
+	}))
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if got := log.last(t).URL.Path; got != "/v2/update" {
+		t.Errorf("path = %q, want the redirect followed", got)
+	}
+}
+
+func TestRedirectPolicyInsecure(t *testing.T) {
+	for _, policy := range []RedirectPolicy{RedirectRefuseCrossHost, RedirectLog} {
+		c := &ClientC{}
+		check := c.checkRedirect(policy)
+
+		via, _ := http.NewRequest(http.MethodGet, "https://www.duckdns.org/update", nil)
+		req, _ := http.NewRequest(http.MethodGet, "http://www.duckdns.org/update", nil)
+		if err := check(req, []*http.Request{via}); !errors.Is(err, ErrInsecureRedirect) {
+			t.Errorf("policy %d: https to http redirect error = %v, want %v", policy, err, ErrInsecureRedirect)
+		}
+	}
+}
+
+func TestWithRedirectPolicySuppliedClient(t *testing.T) {
+	httpClient := &http.Client{}
+	NewClient(httpClient, newTestConfig(), WithRedirectPolicy(RedirectLog))
+	if httpClient.CheckRedirect != nil {
+		t.Error("WithRedirectPolicy() changed a supplied http client")
+	}
+}
@@ -0,0 +1,73 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"testing"
+)
+
+// requireChanged is a validator accepting verbose responses reporting a change
+func requireChanged(response *Response) error {
+	result, err := ParseVerboseResult(response.Data)
+	if err != nil {
+		return err
+	}
+	if !result.Changed {
+		return errNotChanged
+	}
+	return nil
+}
+
+var errNotChanged = errors.New("record not changed")
+
+func TestResponseValidator(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		wantErr error
+	}{
+		{name: "accepted", body: verboseBody},
+		{name: "rejected", body: "OK\n203.0.113.7\n2001:db8::1\nNOCHANGE", wantErr: errNotChanged},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			config := newTestConfig()
+			config.Verbose = true
+			var log requestLog
+			c := newTestClient(t, config, log.wrap(respond(http.StatusOK, tt.body)))
+			c.ResponseValidator = requireChanged
+
+			_, err := c.UpdateRecord(context.Background(), "value")
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("UpdateRecord() error = %v, want %v", err, tt.wantErr)
+			}
+			// a rejection by the validator is not retried
+			if got := log.count(); got != 1 {
+				t.Errorf("requests = %d, want 1", got)
+			}
+
+			_, lastErr := c.LastError()
+			if !errors.Is(lastErr, tt.wantErr) {
+				t.Errorf("LastError() = %v, want %v", lastErr, tt.wantErr)
+			}
+		})
+	}
+}
+
+func TestResponseValidatorSkippedOnFailure(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "KO"))
+	var calls int
+	c.ResponseValidator = func(*Response) error {
+		calls++
+		return nil
+	}
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); !errors.Is(err, ErrRequestRejected) {
+		t.Fatalf("UpdateRecord() error = %v, want %v", err, ErrRequestRejected)
+	}
+	if calls != 0 {
+		t.Errorf("validator calls = %d, want 0", calls)
+	}
+}
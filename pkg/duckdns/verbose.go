@@ -0,0 +1,142 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"net/url"
+	"slices"
+	"strings"
+
+	"k8s.io/klog/v2"
+)
+
+// VerboseResult structure containing the data of a verbose=true response,
+// which duckdns returns as the status, IPv4, IPv6 and change lines
+type VerboseResult struct {
+	Status  Status
+	IPv4    string
+	IPv6    string
+	Changed bool
+
+	// Echo holds the parameters echoed back after the change line, as
+	// key=value query lines, empty when duckdns does not echo them
+	Echo url.Values
+}
+
+// DefaultChangedTokens are the change lines of a verbose response reporting
+// that the update changed a record
+var DefaultChangedTokens = []string{"UPDATED"}
+
+// ParseVerboseResult function to parse the body of a verbose response
+func ParseVerboseResult(data string) (*VerboseResult, error) {
+	return ParseVerboseResultWith(data, DefaultChangedTokens)
+}
+
+// ParseVerboseResultWith function to parse the body of a verbose response,
+// reporting a change when the change line is one of changedTokens, in any
+// case, so that a change of duckdns wording needs no code change
+func ParseVerboseResultWith(data string, changedTokens []string) (*VerboseResult, error) {
+	lines := strings.Split(strings.TrimSpace(data), "\n")
+	if lines[0] == "" {
+		return nil, errors.New("empty verbose response")
+	}
+
+	result := &VerboseResult{Status: ParseStatus(lines[0])}
+	if len(lines) > 1 {
+		result.IPv4 = strings.TrimSpace(lines[1])
+	}
+	if len(lines) > 2 {
+		result.IPv6 = strings.TrimSpace(lines[2])
+	}
+	if len(lines) > 3 {
+		line := strings.TrimSpace(lines[3])
+		result.Changed = slices.ContainsFunc(changedTokens, func(token string) bool {
+			return strings.EqualFold(line, token)
+		})
+	}
+	if len(lines) > 4 {
+		for _, line := range lines[4:] {
+			line = strings.TrimSpace(line)
+			if !strings.Contains(line, "=") {
+				continue
+			}
+			echo, err := url.ParseQuery(line)
+			if err != nil {
+				continue
+			}
+			if result.Echo == nil {
+				result.Echo = make(url.Values)
+			}
+			for key, values := range echo {
+				result.Echo[key] = append(result.Echo[key], values...)
+			}
+		}
+	}
+
+	return result, nil
+}
+
+// WithChangedTokens option to set the change lines of a verbose response
+// reporting a change, defaults to DefaultChangedTokens
+func WithChangedTokens(tokens ...string) Option {
+	return func(c *ClientC) error {
+		if len(tokens) == 0 {
+			return errors.New("at least one changed token is required")
+		}
+		c.changedTokens = slices.Clone(tokens)
+		return nil
+	}
+}
+
+// parseVerboseResult parses a verbose response with the changed tokens of
+// the client
+func (c *ClientC) parseVerboseResult(data string) (*VerboseResult, error) {
+	if c.changedTokens == nil {
+		return ParseVerboseResult(data)
+	}
+	return ParseVerboseResultWith(data, c.changedTokens)
+}
+
+// verboseKey marks a context whose request must be sent with verbose=true
+type verboseKey struct{}
+
+// requestedVerbose reports whether the caller asked for a verbose response,
+// either through Config.Verbose or a request-scoped verbose method
+func (c *ClientC) requestedVerbose(ctx context.Context) bool {
+	return c.Config.Verbose || (ctx != nil && ctx.Value(verboseKey{}) != nil)
+}
+
+// verbose reports whether verbose=true must be sent with a request
+func (c *ClientC) verbose(ctx context.Context) bool {
+	return c.requestedVerbose(ctx) || c.AlwaysVerbose
+}
+
+// logVerbose logs the verbose result of an AlwaysVerbose request and reduces
+// the response data to its status line when the caller did not ask for verbose
+func (c *ClientC) logVerbose(ctx context.Context, response *Response) {
+	if !c.AlwaysVerbose || response == nil {
+		return
+	}
+
+	result, err := c.parseVerboseResult(response.Data)
+	if err != nil {
+		return
+	}
+	klog.Infof("%sVerbose result: status=%v ipv4=%v ipv6=%v changed=%v", c.logPrefix, result.Status, result.IPv4, result.IPv6, result.Changed)
+
+	if !c.requestedVerbose(ctx) && result.Status != StatusUnknown {
+		response.Data = string(result.Status)
+	}
+}
+
+// UpdateRecordVerbose function to update TXT record with a verbose response,
+// without touching the shared Config.Verbose so it is safe for concurrent use
+func (c *ClientC) UpdateRecordVerbose(ctx context.Context, record string) (*Response, error) {
+	return c.UpdateRecord(context.WithValue(ctx, verboseKey{}, true), record)
+}
+
+// ClearRecordVerbose function to clear TXT record with a verbose response,
+// without touching the shared Config.Verbose so it is safe for concurrent use
+func (c *ClientC) ClearRecordVerbose(ctx context.Context, record string) (*Response, error) {
+	return c.ClearRecord(context.WithValue(ctx, verboseKey{}, true), record)
+}
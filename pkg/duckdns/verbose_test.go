@@ -0,0 +1,198 @@
+package duckdns
+
+import (
+	"context"
+	"net/http"
+	"net/url"
+	"reflect"
+	"sync"
+	"testing"
+)
+
+// verboseBody is a verbose response reporting an update
+const verboseBody = "OK\n203.0.113.7\n2001:db8::1\nUPDATED"
+
+func TestAlwaysVerbose(t *testing.T) {
+	logs := captureLogs(t, 0)
+
+	var log requestLog
+	c := newTestClient(t, newTestConfig(), log.wrap(respond(http.StatusOK, verboseBody)))
+	c.AlwaysVerbose = true
+
+	resp, err := c.UpdateRecord(context.Background(), "value")
+	if err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if got := log.last(t).URL.Query().Get("verbose"); got != "true" {
+		t.Errorf("verbose = %q, want true", got)
+	}
+	if resp.Data != "OK" {
+		t.Errorf("Data = %q, want the status line only", resp.Data)
+	}
+	if got := logs.lines("Verbose result: status=OK ipv4=203.0.113.7 ipv6=2001:db8::1 changed=true"); len(got) != 1 {
+		t.Errorf("verbose result logged %d times, want 1, logs:\n%s", len(got), logs)
+	}
+
+	resp, err = c.UpdateRecordVerbose(context.Background(), "value")
+	if err != nil {
+		t.Fatalf("UpdateRecordVerbose() error = %v", err)
+	}
+	if resp.Data != verboseBody {
+		t.Errorf("Data = %q, want the full verbose body when requested", resp.Data)
+	}
+}
+
+func TestAlwaysVerboseKO(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "KO"))
+	c.AlwaysVerbose = true
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err == nil {
+		t.Error("UpdateRecord() error = nil, want KO reported")
+	}
+}
+
+func TestConcurrentVerboseAndPlainRequests(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Query().Get("verbose") == "true" {
+			w.Write([]byte(verboseBody))
+			return
+		}
+		w.Write([]byte("OK"))
+	})
+
+	var wg sync.WaitGroup
+	for i := 0; i < 16; i++ {
+		wg.Add(1)
+		go func(verbose bool) {
+			defer wg.Done()
+
+			update, want := c.UpdateRecord, "OK"
+			if verbose {
+				update, want = c.UpdateRecordVerbose, verboseBody
+			}
+			resp, err := update(context.Background(), "value")
+			if err != nil {
+				t.Errorf("update error = %v", err)
+				return
+			}
+			if resp.Data != want {
+				t.Errorf("verbose %v: Data = %q, want %q", verbose, resp.Data, want)
+			}
+		}(i%2 == 0)
+	}
+	wg.Wait()
+
+	if c.Config.IsVerbose() {
+		t.Error("Config.Verbose set by a request-scoped verbose call")
+	}
+}
+
+func TestParseVerboseResultEcho(t *testing.T) {
+	result, err := ParseVerboseResult(verboseBody + "\ndomains=example&txt=a%26b%3Dc\nclear=false\nnot an echo")
+	if err != nil {
+		t.Fatalf("ParseVerboseResult() error = %v", err)
+	}
+	want := url.Values{"domains": {"example"}, "txt": {"a&b=c"}, "clear": {"false"}}
+	if !reflect.DeepEqual(result.Echo, want) {
+		t.Errorf("Echo = %v, want %v", result.Echo, want)
+	}
+	if result.Status != StatusOK || !result.Changed {
+		t.Errorf("ParseVerboseResult() = %+v, want an OK change", result)
+	}
+}
+
+func TestParseVerboseResultWithoutEcho(t *testing.T) {
+	result, err := ParseVerboseResult(verboseBody)
+	if err != nil {
+		t.Fatalf("ParseVerboseResult() error = %v", err)
+	}
+	if result.Echo != nil {
+		t.Errorf("Echo = %v, want none", result.Echo)
+	}
+	want := VerboseResult{Status: StatusOK, IPv4: "203.0.113.7", IPv6: "2001:db8::1", Changed: true}
+	if !reflect.DeepEqual(*result, want) {
+		t.Errorf("ParseVerboseResult() = %+v, want %+v", *result, want)
+	}
+}
+
+func TestIsVerbose(t *testing.T) {
+	config := newTestConfig()
+	if config.IsVerbose() {
+		t.Fatal("IsVerbose() = true by default, want false")
+	}
+
+	config.SetVerbose(true)
+	if !config.IsVerbose() {
+		t.Error("IsVerbose() = false after SetVerbose(true)")
+	}
+	config.SetVerbose(false)
+	if config.IsVerbose() {
+		t.Error("IsVerbose() = true after SetVerbose(false)")
+	}
+}
+
+func TestParseVerboseResultWith(t *testing.T) {
+	tests := []struct {
+		name   string
+		tokens []string
+		change string
+		want   bool
+	}{
+		{name: "default updated", tokens: DefaultChangedTokens, change: "UPDATED", want: true},
+		{name: "default nochange", tokens: DefaultChangedTokens, change: "NOCHANGE"},
+		{name: "custom", tokens: []string{"CHANGED", "MODIFIED"}, change: "MODIFIED", want: true},
+		{name: "custom any case", tokens: []string{"CHANGED"}, change: " changed ", want: true},
+		{name: "custom replaces default", tokens: []string{"CHANGED"}, change: "UPDATED"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			result, err := ParseVerboseResultWith("OK\n203.0.113.7\n\n"+tt.change, tt.tokens)
+			if err != nil {
+				t.Fatalf("ParseVerboseResultWith() error = %v", err)
+			}
+			if result.Changed != tt.want {
+				t.Errorf("Changed = %v, want %v", result.Changed, tt.want)
+			}
+			if result.Status != StatusOK || result.IPv4 != "203.0.113.7" {
+				t.Errorf("result = %+v, want the other lines parsed as usual", result)
+			}
+		})
+	}
+}
+
+func TestWithChangedTokens(t *testing.T) {
+	logs := captureLogs(t, 0)
+
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK\n203.0.113.7\n\nCHANGED"), WithChangedTokens("CHANGED"))
+	c.AlwaysVerbose = true
+
+	if _, err := c.UpdateRecord(context.Background(), "value"); err != nil {
+		t.Fatalf("UpdateRecord() error = %v", err)
+	}
+	if got := logs.lines("changed=true"); len(got) != 1 {
+		t.Errorf("logs:\n%s\nwant the change reported with the configured token", logs)
+	}
+
+	result, err := c.parseVerboseResult(verboseBody)
+	if err != nil || result.Changed {
+		t.Errorf("parseVerboseResult(UPDATED) = %+v, %v, want no change with the configured tokens", result, err)
+	}
+}
+
+func TestWithChangedTokensEmpty(t *testing.T) {
+	if err := WithChangedTokens()(&ClientC{}); err == nil {
+		t.Error("WithChangedTokens() error = nil, want an error")
+	}
+}
+
+func TestWithChangedTokensCopied(t *testing.T) {
+	tokens := []string{"CHANGED"}
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithChangedTokens(tokens...))
+	tokens[0] = "UPDATED"
+
+	result, err := c.parseVerboseResult(verboseBody)
+	if err != nil || result.Changed {
+		t.Errorf("parseVerboseResult(UPDATED) = %+v, %v, want the tokens given at construction", result, err)
+	}
+}
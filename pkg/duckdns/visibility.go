@@ -0,0 +1,178 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+	"slices"
+	"strings"
+
+	"github.com/miekg/dns"
+)
+
+// rootServers are the addresses of a few dns root servers, where an
+// iterative resolution starts
+var rootServers = []string{
+	"198.41.0.4",   // a.root-servers.net
+	"192.33.4.12",  // c.root-servers.net
+	"199.7.91.13",  // d.root-servers.net
+	"192.5.5.241",  // f.root-servers.net
+	"193.0.14.129", // k.root-servers.net
+}
+
+// maxResolutionSteps bounds the referrals and CNAMEs followed by an
+// iterative resolution
+const maxResolutionSteps = 24
+
+// VerifyChallengeVisible function to report whether the TXT record ACME looks
+// up for fqdn holds value, resolved iteratively from the root servers the way
+// the ACME server does, with no resolver cache involved. CNAMEs are followed,
+// so a challenge delegated to duckdns from another zone is verified too.
+func (c *ClientC) VerifyChallengeVisible(ctx context.Context, fqdn, value string) (bool, error) {
+	name := strings.TrimPrefix(strings.ToLower(strings.TrimSuffix(fqdn, ".")), "*.")
+	if !strings.HasPrefix(name, challengePrefix) {
+		name = challengePrefix + name
+	}
+
+	txt, err := c.resolveTXTIterative(ctx, dns.Fqdn(name))
+	if err != nil {
+		return false, err
+	}
+	return slices.Contains(txt, value), nil
+}
+
+// resolveTXTIterative resolves the TXT records of name by following the
+// referrals from the root servers, a missing record is no records
+func (c *ClientC) resolveTXTIterative(ctx context.Context, name string) ([]string, error) {
+	servers := rootServers
+	for step := 0; step < maxResolutionSteps; step++ {
+		resp, err := c.exchangeAny(ctx, name, servers)
+		if err != nil {
+			return nil, err
+		}
+		if resp.Rcode == dns.RcodeNameError {
+			return nil, nil
+		}
+
+		var txt []string
+		var cname string
+		for _, rr := range resp.Answer {
+			switch rr := rr.(type) {
+			case *dns.TXT:
+				if strings.EqualFold(rr.Hdr.Name, name) {
+					txt = append(txt, strings.Join(rr.Txt, ""))
+				}
+			case *dns.CNAME:
+				if strings.EqualFold(rr.Hdr.Name, name) {
+					cname = rr.Target
+				}
+			}
+		}
+		switch {
+		case len(txt) > 0:
+			return txt, nil
+		case cname != "":
+			name, servers = cname, rootServers
+			continue
+		case resp.Authoritative:
+			return nil, nil
+		}
+
+		servers, err = c.referralServers(ctx, resp)
+		if err != nil {
+			return nil, fmt.Errorf("unable to follow referral for %v: %w", name, err)
+		}
+	}
+
+	return nil, fmt.Errorf("too many referrals resolving %v", name)
+}
+
+// exchangeAny sends a non-recursive TXT query for name to the servers in turn
+// until one answers, a truncated udp answer is retried over tcp
+func (c *ClientC) exchangeAny(ctx context.Context, name string, servers []string) (*dns.Msg, error) {
+	msg := new(dns.Msg)
+	msg.SetQuestion(name, dns.TypeTXT)
+	msg.RecursionDesired = false
+
+	var errs []error
+	for _, server := range servers {
+		addr := net.JoinHostPort(server, "53")
+
+		resp, err := c.exchange(ctx, msg, "udp", addr)
+		if err == nil && resp.Truncated {
+			resp, err = c.exchange(ctx, msg, "tcp", addr)
+		}
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", server, err))
+			continue
+		}
+		if resp.Rcode != dns.RcodeSuccess && resp.Rcode != dns.RcodeNameError {
+			errs = append(errs, fmt.Errorf("%s: %s", server, dns.RcodeToString[resp.Rcode]))
+			continue
+		}
+		return resp, nil
+	}
+
+	if len(errs) == 0 {
+		return nil, errors.New("no nameserver to query")
+	}
+	return nil, errors.Join(errs...)
+}
+
+// exchange sends msg to the nameserver at addr over network, through the
+// dialer of WithResolverDialer when set
+func (c *ClientC) exchange(ctx context.Context, msg *dns.Msg, network, addr string) (*dns.Msg, error) {
+	client := &dns.Client{Net: network}
+	if c.resolverDial == nil {
+		resp, _, err := client.ExchangeContext(ctx, msg, addr)
+		return resp, err
+	}
+
+	conn, err := c.resolverDial(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	defer conn.Close()
+
+	resp, _, err := client.ExchangeWithConnContext(ctx, msg, &dns.Conn{Conn: conn})
+	return resp, err
+}
+
+// referralServers returns the addresses of the nameservers a referral points
+// to, from its glue records or, without glue, looked up with the resolver
+func (c *ClientC) referralServers(ctx context.Context, resp *dns.Msg) ([]string, error) {
+	var hosts []string
+	for _, rr := range resp.Ns {
+		if ns, ok := rr.(*dns.NS); ok {
+			hosts = append(hosts, ns.Ns)
+		}
+	}
+	if len(hosts) == 0 {
+		return nil, errors.New("no nameserver in referral")
+	}
+
+	var servers []string
+	for _, rr := range resp.Extra {
+		if a, ok := rr.(*dns.A); ok && slices.ContainsFunc(hosts, func(h string) bool { return strings.EqualFold(h, a.Hdr.Name) }) {
+			servers = append(servers, a.A.String())
+		}
+	}
+	if len(servers) > 0 {
+		return servers, nil
+	}
+
+	for _, host := range hosts {
+		addrs, err := c.resolver.LookupIP(ctx, "ip4", strings.TrimSuffix(host, "."))
+		if err != nil {
+			continue
+		}
+		for _, addr := range addrs {
+			servers = append(servers, addr.String())
+		}
+		if len(servers) > 0 {
+			return servers, nil
+		}
+	}
+	return nil, fmt.Errorf("unable to resolve nameservers %v", hosts)
+}
@@ -0,0 +1,246 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"testing"
+
+	"github.com/miekg/dns"
+)
+
+// fakeNameserver is a non-recursive dns server authoritative for the names of
+// its records, referring the names of a delegated zone to its nameserver and
+// answering NXDOMAIN to any other name
+type fakeNameserver struct {
+	mu        sync.Mutex
+	records   map[string][]dns.RR
+	referrals map[string]referral
+	queries   []string
+}
+
+// referral is the delegation of a zone to the nameserver host at ip, with or
+// without glue record
+type referral struct {
+	host string
+	ip   string
+	glue bool
+}
+
+func newFakeNameserver() *fakeNameserver {
+	return &fakeNameserver{records: make(map[string][]dns.RR), referrals: make(map[string]referral)}
+}
+
+// add adds the records of rr, given in zone file format
+func (n *fakeNameserver) add(t *testing.T, rr string) {
+	t.Helper()
+	record, err := dns.NewRR(rr)
+	if err != nil {
+		t.Fatalf("invalid record %q: %v", rr, err)
+	}
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	name := strings.ToLower(record.Header().Name)
+	n.records[name] = append(n.records[name], record)
+}
+
+// delegate refers the names of zone to the nameserver host at ip
+func (n *fakeNameserver) delegate(zone, host, ip string, glue bool) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	n.referrals[zoneName(zone)] = referral{host: dns.Fqdn(host), ip: ip, glue: glue}
+}
+
+// count returns the number of queries received for name
+func (n *fakeNameserver) count(name string) int {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	total := 0
+	for _, q := range n.queries {
+		if q == zoneName(name) {
+			total++
+		}
+	}
+	return total
+}
+
+func (n *fakeNameserver) ServeDNS(w dns.ResponseWriter, r *dns.Msg) {
+	m := new(dns.Msg)
+	m.SetReply(r)
+	name := strings.ToLower(r.Question[0].Name)
+
+	n.mu.Lock()
+	n.queries = append(n.queries, name)
+	if records, ok := n.records[name]; ok {
+		m.Authoritative = true
+		m.Answer = append(m.Answer, records...)
+	} else if zone, ref, ok := n.referral(name); ok {
+		m.Ns = append(m.Ns, &dns.NS{Hdr: dns.RR_Header{Name: zone, Rrtype: dns.TypeNS, Class: dns.ClassINET, Ttl: 300}, Ns: ref.host})
+		if ref.glue {
+			m.Extra = append(m.Extra, &dns.A{Hdr: dns.RR_Header{Name: ref.host, Rrtype: dns.TypeA, Class: dns.ClassINET, Ttl: 300}, A: net.ParseIP(ref.ip)})
+		}
+	} else {
+		m.Authoritative = true
+		m.Rcode = dns.RcodeNameError
+	}
+	n.mu.Unlock()
+
+	writeTruncated(w, r, m)
+}
+
+// referral returns the longest delegated zone holding name
+func (n *fakeNameserver) referral(name string) (string, referral, bool) {
+	var zone string
+	for z := range n.referrals {
+		if (name == z || strings.HasSuffix(name, "."+z)) && len(z) > len(zone) {
+			zone = z
+		}
+	}
+	ref, ok := n.referrals[zone]
+	return zone, ref, ok
+}
+
+// fakeDNSChain is a delegation chain from a root server down to the duckdns
+// and example.com zones, reached at their public addresses through the
+// resolver dialer of the client
+type fakeDNSChain struct {
+	root, org, duckdns, com *fakeNameserver
+
+	// recursive resolves the nameserver hosts of the referrals without glue
+	recursive *fakeZone
+
+	addrs map[string]string
+}
+
+func newFakeDNSChain(t *testing.T) *fakeDNSChain {
+	t.Helper()
+
+	chain := &fakeDNSChain{
+		root:      newFakeNameserver(),
+		org:       newFakeNameserver(),
+		duckdns:   newFakeNameserver(),
+		com:       newFakeNameserver(),
+		recursive: newFakeZone(t),
+	}
+	chain.root.delegate("org", "a0.org-servers.test", "192.0.2.1", true)
+	chain.root.delegate("com", "a.gtld-servers.test", "192.0.2.3", true)
+	chain.org.delegate("duckdns.org", "ns1.duckdns.org", "192.0.2.2", true)
+	chain.com.add(t, "_acme-challenge.example.com. 300 IN CNAME _acme-challenge.example.duckdns.org.")
+
+	chain.addrs = map[string]string{
+		net.JoinHostPort(rootServers[0], "53"): serveDNS(t, chain.root),
+		"192.0.2.1:53":                         serveDNS(t, chain.org),
+		"192.0.2.2:53":                         serveDNS(t, chain.duckdns),
+		"192.0.2.3:53":                         serveDNS(t, chain.com),
+	}
+	return chain
+}
+
+// dial connects to the fake server of address, any other address reaches
+// the recursive resolver
+func (f *fakeDNSChain) dial(ctx context.Context, network, address string) (net.Conn, error) {
+	addr, ok := f.addrs[address]
+	if !ok {
+		addr = f.recursive.addr
+	}
+	var d net.Dialer
+	return d.DialContext(ctx, network, addr)
+}
+
+func (f *fakeDNSChain) client(t *testing.T) *ClientC {
+	return newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithResolverDialer(f.dial))
+}
+
+func TestVerifyChallengeVisible(t *testing.T) {
+	tests := []struct {
+		name  string
+		fqdn  string
+		value string
+		want  bool
+	}{
+		{name: "visible", fqdn: "example.duckdns.org", value: "value", want: true},
+		{name: "other value", fqdn: "example.duckdns.org", value: "other"},
+		{name: "wildcard", fqdn: "*.example.duckdns.org.", value: "value", want: true},
+		{name: "challenge name", fqdn: "_acme-challenge.Example.duckdns.org", value: "value", want: true},
+		{name: "cname delegation", fqdn: "example.com", value: "value", want: true},
+		{name: "missing", fqdn: "missing.duckdns.org", value: "value"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			chain := newFakeDNSChain(t)
+			chain.duckdns.add(t, `_acme-challenge.example.duckdns.org. 300 IN TXT "stale"`)
+			chain.duckdns.add(t, `_acme-challenge.example.duckdns.org. 300 IN TXT "value"`)
+
+			got, err := chain.client(t).VerifyChallengeVisible(context.Background(), tt.fqdn, tt.value)
+			if err != nil {
+				t.Fatalf("VerifyChallengeVisible() error = %v", err)
+			}
+			if got != tt.want {
+				t.Errorf("VerifyChallengeVisible() = %v, want %v", got, tt.want)
+			}
+			// the resolution walks the chain, nothing is asked to a resolver
+			if got := chain.recursive.count(dns.TypeTXT, "_acme-challenge.example.duckdns.org"); got != 0 {
+				t.Errorf("recursive TXT lookups = %d, want 0", got)
+			}
+		})
+	}
+}
+
+func TestVerifyChallengeVisibleCNAMERestartsAtRoot(t *testing.T) {
+	chain := newFakeDNSChain(t)
+	chain.duckdns.add(t, `_acme-challenge.example.duckdns.org. 300 IN TXT "value"`)
+
+	if ok, err := chain.client(t).VerifyChallengeVisible(context.Background(), "example.com", "value"); err != nil || !ok {
+		t.Fatalf("VerifyChallengeVisible() = %v, %v, want true", ok, err)
+	}
+	for _, name := range []string{"_acme-challenge.example.com", "_acme-challenge.example.duckdns.org"} {
+		if got := chain.root.count(name); got != 1 {
+			t.Errorf("root queries for %s = %d, want 1", name, got)
+		}
+	}
+}
+
+func TestVerifyChallengeVisibleWithoutGlue(t *testing.T) {
+	chain := newFakeDNSChain(t)
+	chain.org.delegate("duckdns.org", "ns1.duckdns.org", "192.0.2.2", false)
+	chain.recursive.setA("ns1.duckdns.org", "192.0.2.2")
+	chain.duckdns.add(t, `_acme-challenge.example.duckdns.org. 300 IN TXT "value"`)
+
+	if ok, err := chain.client(t).VerifyChallengeVisible(context.Background(), "example.duckdns.org", "value"); err != nil || !ok {
+		t.Fatalf("VerifyChallengeVisible() = %v, %v, want true", ok, err)
+	}
+	if got := chain.recursive.count(dns.TypeA, "ns1.duckdns.org"); got == 0 {
+		t.Error("the nameserver of the referral was not looked up")
+	}
+}
+
+func TestVerifyChallengeVisibleTruncated(t *testing.T) {
+	chain := newFakeDNSChain(t)
+	// an answer larger than the 512 bytes of a plain udp query
+	for i := 0; i < 4; i++ {
+		chain.duckdns.add(t, `_acme-challenge.example.duckdns.org. 300 IN TXT "`+strings.Repeat("x", 200)+`"`)
+	}
+	chain.duckdns.add(t, `_acme-challenge.example.duckdns.org. 300 IN TXT "value"`)
+
+	if ok, err := chain.client(t).VerifyChallengeVisible(context.Background(), "example.duckdns.org", "value"); err != nil || !ok {
+		t.Fatalf("VerifyChallengeVisible() = %v, %v, want the tcp answer", ok, err)
+	}
+	if got := chain.duckdns.count("_acme-challenge.example.duckdns.org"); got != 2 {
+		t.Errorf("queries = %d, want a udp query retried over tcp", got)
+	}
+}
+
+func TestVerifyChallengeVisibleUnreachable(t *testing.T) {
+	errUnreachable := errors.New("unreachable")
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"),
+		WithResolverDialer(func(context.Context, string, string) (net.Conn, error) { return nil, errUnreachable }))
+
+	ok, err := c.VerifyChallengeVisible(context.Background(), "example.duckdns.org", "value")
+	if ok || !errors.Is(err, errUnreachable) {
+		t.Fatalf("VerifyChallengeVisible() = %v, %v, want false and %v", ok, err, errUnreachable)
+	}
+}
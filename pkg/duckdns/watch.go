@@ -0,0 +1,88 @@
+package duckdns
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"slices"
+	"time"
+)
+
+// PropagationEvent structure describing one check of WatchPropagation
+type PropagationEvent struct {
+	Attempt int
+
+	// Records holds the TXT records seen per configured domain
+	Records map[string][]string
+
+	// Propagated reports whether every domain resolves the expected value,
+	// it is the last event sent on the channel when true
+	Propagated bool
+
+	// Err holds the lookup error of the check, if any
+	Err error
+}
+
+// WatchPropagation function to poll the configured domains for the expected
+// TXT value every pollInterval and stream every check on the returned channel,
+// which is closed once the value propagated or ctx is done. It is a
+// non-blocking alternative to WaitForRecord for callers reporting progress.
+func (c *ClientC) WatchPropagation(ctx context.Context, expected string, pollInterval time.Duration) (<-chan PropagationEvent, error) {
+	if len(c.Config.DomainNames) == 0 {
+		return nil, errors.New("no domains configured")
+	}
+	if pollInterval <= 0 {
+		return nil, fmt.Errorf("invalid poll interval %v, must be positive", pollInterval)
+	}
+
+	events := make(chan PropagationEvent)
+	go func() {
+		defer close(events)
+
+		ticker := time.NewTicker(pollInterval)
+		defer ticker.Stop()
+
+		for attempt := 1; ; attempt++ {
+			event := c.propagationEvent(ctx, attempt, expected)
+
+			select {
+			case events <- event:
+			case <-ctx.Done():
+				return
+			}
+			if event.Propagated {
+				return
+			}
+
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+
+	return events, nil
+}
+
+// propagationEvent checks every configured domain for the expected TXT value
+func (c *ClientC) propagationEvent(ctx context.Context, attempt int, expected string) PropagationEvent {
+	event := PropagationEvent{
+		Attempt:    attempt,
+		Records:    make(map[string][]string, len(c.Config.DomainNames)),
+		Propagated: true,
+	}
+	for _, domain := range c.Config.DomainNames {
+		txt, err := c.domainTXT(ctx, domain)
+		if err != nil {
+			event.Err = err
+			event.Propagated = false
+			continue
+		}
+		event.Records[domain] = txt
+		if !slices.Contains(txt, expected) {
+			event.Propagated = false
+		}
+	}
+	return event
+}
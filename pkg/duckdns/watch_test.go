@@ -0,0 +1,139 @@
+package duckdns
+
+import (
+	"context"
+	"net/http"
+	"reflect"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestWatchPropagation(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("first.duckdns.org", "value")
+	zone.setTXT("second.duckdns.org", "other", "value")
+	c := newTestClient(t, newTestConfig("first", "second"), respond(http.StatusOK, "OK"), WithResolver(zone.resolver()))
+
+	events, err := c.WatchPropagation(context.Background(), "value", time.Second)
+	if err != nil {
+		t.Fatalf("WatchPropagation() error = %v", err)
+	}
+
+	var got []PropagationEvent
+	for event := range events {
+		got = append(got, event)
+	}
+	if len(got) != 1 {
+		t.Fatalf("events = %d, want 1", len(got))
+	}
+	want := PropagationEvent{
+		Attempt:    1,
+		Records:    map[string][]string{"first": {"value"}, "second": {"other", "value"}},
+		Propagated: true,
+	}
+	if !reflect.DeepEqual(got[0], want) {
+		t.Errorf("event = %+v, want %+v", got[0], want)
+	}
+}
+
+func TestWatchPropagationCanceled(t *testing.T) {
+	zone := newFakeZone(t)
+	zone.setTXT("example.duckdns.org", "old")
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithResolver(zone.resolver()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	events, err := c.WatchPropagation(ctx, "value", time.Second)
+	if err != nil {
+		t.Fatalf("WatchPropagation() error = %v", err)
+	}
+
+	event := <-events
+	if event.Propagated || event.Attempt != 1 || !reflect.DeepEqual(event.Records["example"], []string{"old"}) {
+		t.Errorf("event = %+v, want a first check seeing old", event)
+	}
+
+	cancel()
+	select {
+	case _, open := <-events:
+		if open {
+			t.Error("received an event after cancellation")
+		}
+	case <-time.After(time.Second):
+		t.Fatal("channel not closed after cancellation")
+	}
+}
+
+func TestWatchPropagationNotConsumed(t *testing.T) {
+	zone := newFakeZone(t)
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithResolver(zone.resolver()))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	events, err := c.WatchPropagation(ctx, "value", time.Second)
+	if err != nil {
+		t.Fatalf("WatchPropagation() error = %v", err)
+	}
+
+	// the watcher blocked on sending its first event exits on cancellation
+	time.Sleep(20 * time.Millisecond)
+	cancel()
+	deadline := time.After(time.Second)
+	for {
+		select {
+		case _, open := <-events:
+			if !open {
+				return
+			}
+		case <-deadline:
+			t.Fatal("channel not closed after cancellation")
+		}
+	}
+}
+
+func TestWatchPropagationChangingRecord(t *testing.T) {
+	zone := newFakeZone(t)
+	states := []string{"old", "pending", "value"}
+	zone.setTXT("example.duckdns.org", states[0])
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"), WithResolver(zone.resolver()))
+
+	events, err := c.WatchPropagation(context.Background(), "value", 10*time.Millisecond)
+	if err != nil {
+		t.Fatalf("WatchPropagation() error = %v", err)
+	}
+
+	var seen []string
+	var last PropagationEvent
+	for event := range events {
+		if event.Err != nil {
+			t.Fatalf("event %d error = %v", event.Attempt, event.Err)
+		}
+		records := event.Records["example"]
+		if len(records) != 1 {
+			t.Fatalf("event %d records = %v, want a single value", event.Attempt, records)
+		}
+		// a check may run again before the record moves on, only the changes count
+		if len(seen) == 0 || seen[len(seen)-1] != records[0] {
+			seen = append(seen, records[0])
+		}
+		if i := slices.Index(states, records[0]); i >= 0 && i+1 < len(states) {
+			zone.setTXT("example.duckdns.org", states[i+1])
+		}
+		last = event
+	}
+
+	if !reflect.DeepEqual(seen, states) {
+		t.Errorf("records seen = %v, want %v", seen, states)
+	}
+	if !last.Propagated || last.Attempt < len(states) {
+		t.Errorf("last event = %+v, want propagated after at least %d checks", last, len(states))
+	}
+}
+
+func TestWatchPropagationInvalidInterval(t *testing.T) {
+	c := newTestClient(t, newTestConfig(), respond(http.StatusOK, "OK"))
+
+	if _, err := c.WatchPropagation(context.Background(), "value", 0); err == nil {
+		t.Error("WatchPropagation() error = nil, want an invalid interval error")
+	}
+}